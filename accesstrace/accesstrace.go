@@ -0,0 +1,388 @@
+// Package accesstrace records the accesses made to loading caches into trace files,
+// which capture real access patterns to tune cache sizes and policies.
+//
+// A Recorder is attached to a cache through its middleware:
+//
+//	recorder, err := accesstrace.Create("cache.trace", accesstrace.Options{})
+//	...
+//	cache := loadingcache.New(loadingcache.CacheOptions{
+//		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+//	})
+//
+// Every Get, Put and Invalidate is then written as a record holding a timestamp,
+// a hash of the key, the operation and, for lookups, whether they hit or missed.
+package accesstrace
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/benbjohnson/clock"
+)
+
+// defaultBufferSize is the number of records buffered when not configured
+const defaultBufferSize = 4096
+
+// Op is a cache operation
+type Op uint8
+
+const (
+	// OpGet is a lookup with Get or GetContext
+	OpGet Op = iota + 1
+
+	// OpPut is a Put
+	OpPut
+
+	// OpInvalidate is an Invalidate. Invalidating several keys results in a record per key.
+	OpInvalidate
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpGet:
+		return "get"
+	case OpPut:
+		return "put"
+	case OpInvalidate:
+		return "invalidate"
+	default:
+		return "op(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// Result is the outcome of a lookup
+type Result uint8
+
+const (
+	// ResultNone is the result of operations other than lookups
+	ResultNone Result = iota
+
+	// ResultHit means a cached value was found
+	ResultHit
+
+	// ResultMiss means no cached value was found, whether or not one was then loaded
+	ResultMiss
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultNone:
+		return ""
+	case ResultHit:
+		return "hit"
+	case ResultMiss:
+		return "miss"
+	default:
+		return "result(" + strconv.Itoa(int(r)) + ")"
+	}
+}
+
+// Record is a single access
+type Record struct {
+	Time    time.Time
+	KeyHash uint64
+	Op      Op
+	Result  Result
+}
+
+// Format is the encoding of a trace file
+type Format int
+
+const (
+	// FormatBinary encodes traces as a header followed by fixed size records.
+	// See Reader for details.
+	FormatBinary Format = iota
+
+	// FormatCSV encodes traces as CSV, with a header line followed by a line per record
+	// holding the unix timestamp in nanoseconds, the key hash, the operation and the result.
+	FormatCSV
+)
+
+// Options configures a Recorder.
+type Options struct {
+	// Format is the encoding of the trace. Defaults to FormatBinary.
+	Format Format
+
+	// SampleRate is the fraction of keys whose accesses are recorded, between 0 and 1.
+	// Keys are sampled by their hash, so either all or none of the accesses to a given
+	// key are recorded, which keeps the sampled trace representative of hit rates.
+	//
+	// If not specified, all accesses are recorded.
+	SampleRate float64
+
+	// MaxBytes limits the size of the trace. Once reached, further records are dropped.
+	//
+	// If not specified, the size is unlimited.
+	MaxBytes int64
+
+	// BufferSize is the number of records which can be waiting to be written.
+	// Records are dropped rather than blocking cache operations when the buffer is full.
+	//
+	// If not specified, 4096 records are buffered.
+	BufferSize int
+
+	// HashCodeFunc hashes keys.
+	//
+	// If not specified, loadingcache.DefaultHashCode is used. Since it is randomly
+	// seeded for strings, their hashes are only consistent within a process.
+	HashCodeFunc func(key interface{}) int
+
+	// Clock provides the timestamps of records. If not specified, the system clock is used.
+	Clock clock.Clock
+}
+
+// Recorder writes cache accesses to a trace.
+//
+// Records are queued without blocking, and written by a background go routine.
+// Use Close when done to flush the trace.
+type Recorder struct {
+	w       io.Writer
+	closer  io.Closer
+	format  Format
+	maxSize int64
+
+	// sampleThreshold is the highest mixed key hash which is sampled
+	sampleThreshold uint64
+	hashCodeFunc    func(key interface{}) int
+	clock           clock.Clock
+
+	records chan Record
+	done    chan struct{}
+	stopped chan struct{}
+
+	// mu makes checking whether the recorder is closed and queueing a record atomic,
+	// so records are never queued once Close started draining them
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+	err       error
+
+	written atomic.Int64
+	dropped atomic.Int64
+}
+
+// Create creates a trace file, truncating it if it exists, and returns a recorder
+// which writes to it. The file is closed when the recorder is closed.
+func Create(path string, options Options) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newRecorder(f, f, options)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewRecorder returns a recorder which writes to w.
+func NewRecorder(w io.Writer, options Options) (*Recorder, error) {
+	return newRecorder(w, nil, options)
+}
+
+func newRecorder(w io.Writer, closer io.Closer, options Options) (*Recorder, error) {
+	switch {
+	case options.Format != FormatBinary && options.Format != FormatCSV:
+		return nil, fmt.Errorf("unknown trace format %d", options.Format)
+	case options.SampleRate < 0 || options.SampleRate > 1:
+		return nil, errors.New("sample rate must be between 0 and 1")
+	case options.MaxBytes < 0:
+		return nil, errors.New("max bytes must be non-negative")
+	case options.BufferSize < 0:
+		return nil, errors.New("buffer size must be non-negative")
+	}
+	r := &Recorder{
+		w:               w,
+		closer:          closer,
+		format:          options.Format,
+		maxSize:         options.MaxBytes,
+		sampleThreshold: math.MaxUint64,
+		hashCodeFunc:    options.HashCodeFunc,
+		clock:           options.Clock,
+		done:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+	if options.SampleRate > 0 && options.SampleRate < 1 {
+		r.sampleThreshold = uint64(options.SampleRate * math.MaxUint64)
+	}
+	if r.hashCodeFunc == nil {
+		r.hashCodeFunc = loadingcache.DefaultHashCode
+	}
+	if r.clock == nil {
+		r.clock = clock.New()
+	}
+	bufferSize := options.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultBufferSize
+	}
+	r.records = make(chan Record, bufferSize)
+	go r.run()
+	return r, nil
+}
+
+// Middleware returns a middleware which records the Get, Put and Invalidate operations
+// of a cache. The same recorder may be used by several caches.
+func (r *Recorder) Middleware() loadingcache.Middleware {
+	return func(next loadingcache.Operations) loadingcache.Operations {
+		get, put, invalidate := next.Get, next.Put, next.Invalidate
+		next.Get = func(ctx context.Context, key interface{}) (interface{}, error) {
+			hash, sampled := r.sample(key)
+			if !sampled {
+				return get(ctx, key)
+			}
+			// The trace hooks are called by the lookup on this goroutine,
+			// so there is no need to synchronize. Only hooks for the looked up
+			// key are considered, as a safeguard against unrelated lookups.
+			result := ResultMiss
+			hit := func(hookKey interface{}) {
+				if hookKey == key {
+					result = ResultHit
+				}
+			}
+			ctx = loadingcache.WithTrace(ctx, &loadingcache.Trace{
+				Hit:        hit,
+				SharedLoad: hit,
+			})
+			val, err := get(ctx, key)
+			r.record(hash, OpGet, result)
+			return val, err
+		}
+		next.Put = func(key interface{}, value interface{}) {
+			put(key, value)
+			if hash, sampled := r.sample(key); sampled {
+				r.record(hash, OpPut, ResultNone)
+			}
+		}
+		next.Invalidate = func(key interface{}, keys ...interface{}) {
+			invalidate(key, keys...)
+			for _, k := range append([]interface{}{key}, keys...) {
+				if hash, sampled := r.sample(k); sampled {
+					r.record(hash, OpInvalidate, ResultNone)
+				}
+			}
+		}
+		return next
+	}
+}
+
+// sample hashes a key, and checks whether its accesses are recorded
+func (r *Recorder) sample(key interface{}) (uint64, bool) {
+	hash := uint64(r.hashCodeFunc(key))
+	if r.sampleThreshold == math.MaxUint64 {
+		return hash, true
+	}
+	return hash, mix64(hash) <= r.sampleThreshold
+}
+
+// record queues a record, dropping it if the buffer is full or the recorder is closed
+func (r *Recorder) record(hash uint64, op Op, result Result) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return
+	}
+	select {
+	case r.records <- Record{Time: r.clock.Now(), KeyHash: hash, Op: op, Result: result}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of records which were dropped, because the buffer was
+// full, the maximum size was reached, or the recorder was closed.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Written returns the number of bytes written so far, including the header.
+func (r *Recorder) Written() int64 {
+	return r.written.Load()
+}
+
+// Close stops recording, writes the records which are still buffered, and flushes the
+// trace. If the recorder created the trace file, the file is closed.
+//
+// It returns the first error encountered while writing, after which no more records
+// were written. It is safe to call multiple times.
+func (r *Recorder) Close() error {
+	r.closeOnce.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		r.mu.Unlock()
+		close(r.done)
+		<-r.stopped
+		if r.closer != nil {
+			if err := r.closer.Close(); err != nil && r.err == nil {
+				r.err = err
+			}
+		}
+	})
+	return r.err
+}
+
+// run writes records until the recorder is closed
+func (r *Recorder) run() {
+	defer close(r.stopped)
+	enc := newEncoder(r.format)
+	bw := bufio.NewWriter(r.w)
+	write := func(b []byte) {
+		if r.err != nil {
+			return
+		}
+		if r.maxSize > 0 && r.written.Load()+int64(len(b)) > r.maxSize {
+			r.dropped.Add(1)
+			return
+		}
+		if _, err := bw.Write(b); err != nil {
+			r.err = err
+			return
+		}
+		r.written.Add(int64(len(b)))
+	}
+	write(enc.header())
+
+	for {
+		select {
+		case record := <-r.records:
+			write(enc.encode(record))
+			// Flush once the buffer is drained, so the trace is readable while recording
+			if len(r.records) == 0 && r.err == nil {
+				r.err = bw.Flush()
+			}
+		case <-r.done:
+			for {
+				select {
+				case record := <-r.records:
+					write(enc.encode(record))
+				default:
+					if r.err == nil {
+						r.err = bw.Flush()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// mix64 scrambles the bits of a hash, so any part of it can be used for sampling
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,303 @@
+package accesstrace_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/Hartimer/loadingcache/accesstrace"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// identityHashCodeFunc hashes int keys to themselves
+func identityHashCodeFunc(key interface{}) int {
+	return key.(int)
+}
+
+// runAccesses performs a fixed set of operations on a cache recorded by a given recorder
+func runAccesses(t *testing.T, mockClock *clock.Mock, recorder *accesstrace.Recorder) {
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Clock:       mockClock,
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+		Load: func(key interface{}) (interface{}, error) {
+			if key.(int) < 0 {
+				return nil, errors.New("negative key")
+			}
+			return key, nil
+		},
+	})
+	defer cache.Close()
+
+	_, err := cache.Get(1)
+	require.NoError(t, err)
+	mockClock.Add(time.Second)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(-1)
+	require.Error(t, err)
+	mockClock.Add(time.Second)
+	cache.Put(2, 2)
+	cache.Invalidate(1, 2)
+}
+
+func readAll(t *testing.T, r io.Reader) []accesstrace.Record {
+	reader := accesstrace.NewReader(r)
+	var records []accesstrace.Record
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestRecorderBinary(t *testing.T) {
+	mockClock := clock.NewMock()
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		Clock:        mockClock,
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	runAccesses(t, mockClock, recorder)
+	require.NoError(t, recorder.Close())
+	require.NoError(t, recorder.Close())
+
+	start := time.Unix(0, 0)
+	require.Equal(t, []accesstrace.Record{
+		{Time: start, KeyHash: 1, Op: accesstrace.OpGet, Result: accesstrace.ResultMiss},
+		{Time: start.Add(time.Second), KeyHash: 1, Op: accesstrace.OpGet, Result: accesstrace.ResultHit},
+		{Time: start.Add(time.Second), KeyHash: uint64(1<<64 - 1), Op: accesstrace.OpGet, Result: accesstrace.ResultMiss},
+		{Time: start.Add(2 * time.Second), KeyHash: 2, Op: accesstrace.OpPut},
+		{Time: start.Add(2 * time.Second), KeyHash: 1, Op: accesstrace.OpInvalidate},
+		{Time: start.Add(2 * time.Second), KeyHash: 2, Op: accesstrace.OpInvalidate},
+	}, readAll(t, &buf))
+	require.Equal(t, int64(8+6*18), recorder.Written())
+	require.Zero(t, recorder.Dropped())
+}
+
+func TestRecorderCSV(t *testing.T) {
+	mockClock := clock.NewMock()
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		Format:       accesstrace.FormatCSV,
+		Clock:        mockClock,
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	runAccesses(t, mockClock, recorder)
+	require.NoError(t, recorder.Close())
+
+	require.Equal(t, `timestamp_ns,key_hash,op,result
+0,1,get,miss
+1000000000,1,get,hit
+1000000000,18446744073709551615,get,miss
+2000000000,2,put,
+2000000000,1,invalidate,
+2000000000,2,invalidate,
+`, buf.String())
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		SampleRate:   0.5,
+		BufferSize:   10000,
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+	})
+	defer cache.Close()
+
+	for i := 0; i < 1000; i++ {
+		cache.Put(i, i)
+		_, err := cache.Get(i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, recorder.Close())
+
+	// All accesses of a sampled key are recorded
+	accesses := map[uint64]int{}
+	for _, record := range readAll(t, &buf) {
+		accesses[record.KeyHash]++
+	}
+	require.InDelta(t, 500, len(accesses), 100)
+	for _, count := range accesses {
+		require.Equal(t, 2, count)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		MaxBytes:     8 + 2*18,
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+	})
+	defer cache.Close()
+
+	for i := 0; i < 5; i++ {
+		cache.Put(i, i)
+	}
+	require.NoError(t, recorder.Close())
+
+	require.Len(t, readAll(t, &buf), 2)
+	require.Equal(t, int64(8+2*18), recorder.Written())
+	require.Equal(t, int64(3), recorder.Dropped())
+}
+
+// blockingWriter blocks writes until it is released
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestRecorderDoesNotBlock(t *testing.T) {
+	writer := &blockingWriter{release: make(chan struct{})}
+	recorder, err := accesstrace.NewRecorder(writer, accesstrace.Options{
+		BufferSize: 1,
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+	})
+	defer cache.Close()
+
+	// The writer is stuck, so records pile up and are dropped
+	for i := 0; i < 100; i++ {
+		cache.Put(i, i)
+	}
+	require.Greater(t, recorder.Dropped(), int64(90))
+
+	close(writer.release)
+	require.NoError(t, recorder.Close())
+
+	// Once closed, accesses are no longer recorded
+	dropped := recorder.Dropped()
+	cache.Put(1, 1)
+	require.Equal(t, dropped+1, recorder.Dropped())
+}
+
+func TestRecordWhileClosing(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		BufferSize:   10000,
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+	})
+	defer cache.Close()
+
+	const workers, puts = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < puts; i++ {
+				cache.Put(i, i)
+			}
+		}()
+	}
+	require.NoError(t, recorder.Close())
+	wg.Wait()
+
+	// Every record is either written or counted as dropped
+	records := readAll(t, &buf)
+	require.Equal(t, int64(workers*puts), int64(len(records))+recorder.Dropped())
+}
+
+func TestCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.trace")
+	recorder, err := accesstrace.Create(path, accesstrace.Options{
+		HashCodeFunc: identityHashCodeFunc,
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+	})
+	defer cache.Close()
+	cache.Put(1, 1)
+	require.NoError(t, recorder.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	records := readAll(t, f)
+	require.Len(t, records, 1)
+	require.Equal(t, accesstrace.OpPut, records[0].Op)
+}
+
+func TestInvalidOptions(t *testing.T) {
+	testCases := map[string]accesstrace.Options{
+		"unknown format":       {Format: 3},
+		"negative sample rate": {SampleRate: -1},
+		"sample rate too high": {SampleRate: 2},
+		"negative max bytes":   {MaxBytes: -1},
+		"negative buffer size": {BufferSize: -1},
+	}
+	for name, options := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := accesstrace.NewRecorder(io.Discard, options)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := accesstrace.NewReader(bytes.NewReader([]byte("LCTRACE\x02"))).Read()
+	require.EqualError(t, err, "not a binary trace")
+
+	_, err = accesstrace.NewReader(bytes.NewReader([]byte("LCTRACE\x01\x00"))).Read()
+	require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+func TestLoaderLookingUpAnotherCache(t *testing.T) {
+	inner := loadingcache.New(loadingcache.CacheOptions{})
+	defer inner.Close()
+	inner.Put("x", "x")
+
+	var buf bytes.Buffer
+	recorder, err := accesstrace.NewRecorder(&buf, accesstrace.Options{
+		Format:       accesstrace.FormatCSV,
+		Clock:        clock.NewMock(),
+		HashCodeFunc: func(interface{}) int { return 1 },
+	})
+	require.NoError(t, err)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{recorder.Middleware()},
+		LoadContext: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+			val, err := inner.GetContext(ctx, "x")
+			return loadingcache.LoadResult{Value: val}, err
+		},
+	})
+	defer cache.Close()
+
+	_, err = cache.Get("y")
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	// The hit of the inner cache is not mistaken for the outer lookup
+	require.Equal(t, "timestamp_ns,key_hash,op,result\n0,1,get,miss\n", buf.String())
+}
@@ -0,0 +1,114 @@
+package accesstrace
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// binaryMagic starts binary traces, the last byte being the format version
+	binaryMagic = "LCTRACE\x01"
+
+	// binaryRecordSize is the size of an encoded binary record
+	binaryRecordSize = 18
+
+	csvHeader = "timestamp_ns,key_hash,op,result\n"
+)
+
+// encoder converts records into bytes. The returned slices are only valid
+// until the next call.
+type encoder interface {
+	header() []byte
+	encode(record Record) []byte
+}
+
+func newEncoder(format Format) encoder {
+	if format == FormatCSV {
+		return &csvEncoder{}
+	}
+	return &binaryEncoder{}
+}
+
+// binaryEncoder encodes each record as 18 little endian bytes: the unix timestamp in
+// nanoseconds (8 bytes), the key hash (8 bytes), the operation (1 byte) and the result (1 byte).
+type binaryEncoder struct {
+	buf [binaryRecordSize]byte
+}
+
+func (e *binaryEncoder) header() []byte {
+	return []byte(binaryMagic)
+}
+
+func (e *binaryEncoder) encode(record Record) []byte {
+	binary.LittleEndian.PutUint64(e.buf[0:8], uint64(record.Time.UnixNano()))
+	binary.LittleEndian.PutUint64(e.buf[8:16], record.KeyHash)
+	e.buf[16] = byte(record.Op)
+	e.buf[17] = byte(record.Result)
+	return e.buf[:]
+}
+
+type csvEncoder struct {
+	buf []byte
+}
+
+func (e *csvEncoder) header() []byte {
+	return []byte(csvHeader)
+}
+
+func (e *csvEncoder) encode(record Record) []byte {
+	e.buf = strconv.AppendInt(e.buf[:0], record.Time.UnixNano(), 10)
+	e.buf = append(e.buf, ',')
+	e.buf = strconv.AppendUint(e.buf, record.KeyHash, 10)
+	e.buf = append(e.buf, ',')
+	e.buf = append(e.buf, record.Op.String()...)
+	e.buf = append(e.buf, ',')
+	e.buf = append(e.buf, record.Result.String()...)
+	e.buf = append(e.buf, '\n')
+	return e.buf
+}
+
+// Reader reads binary traces.
+//
+// Binary traces start with the 8 byte header "LCTRACE\x01", followed by 18 byte records
+// holding, in little endian order, the unix timestamp in nanoseconds (8 bytes), the key
+// hash (8 bytes), the operation (1 byte) and the result (1 byte).
+type Reader struct {
+	r          io.Reader
+	readHeader bool
+	buf        [binaryRecordSize]byte
+}
+
+// NewReader returns a reader of the binary trace held by r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Read returns the next record, or io.EOF once all records were read.
+func (r *Reader) Read() (Record, error) {
+	if !r.readHeader {
+		header := make([]byte, len(binaryMagic))
+		if _, err := io.ReadFull(r.r, header); err != nil {
+			return Record{}, fmt.Errorf("reading trace header: %w", err)
+		}
+		if string(header) != binaryMagic {
+			return Record{}, errors.New("not a binary trace")
+		}
+		r.readHeader = true
+	}
+	if _, err := io.ReadFull(r.r, r.buf[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return Record{}, fmt.Errorf("truncated trace record: %w", err)
+		}
+		return Record{}, err
+	}
+	return Record{
+		Time:    time.Unix(0, int64(binary.LittleEndian.Uint64(r.buf[0:8]))),
+		KeyHash: binary.LittleEndian.Uint64(r.buf[8:16]),
+		Op:      Op(r.buf[16]),
+		Result:  Result(r.buf[17]),
+	}, nil
+}
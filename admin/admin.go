@@ -0,0 +1,372 @@
+// Package admin provides an HTTP handler to inspect and operate loading caches,
+// e.g. on an internal debug port.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+)
+
+// defaultSampleSize is the number of keys listed when no limit is requested
+const defaultSampleSize = 100
+
+// Options configures the handler.
+type Options struct {
+	// Registry holds the caches to expose.
+	//
+	// If not specified, loadingcache.DefaultRegistry is used.
+	Registry *loadingcache.Registry
+
+	// Authorize is called before serving every request. If it returns an error,
+	// the request is rejected with http.StatusForbidden.
+	//
+	// If not specified, all requests are allowed, so the handler must only be
+	// reachable by trusted clients.
+	Authorize func(r *http.Request) error
+
+	// ParseKey converts a key received in a request into a key of the named cache.
+	//
+	// If not specified, keys are used as strings.
+	ParseKey func(cache string, key string) (interface{}, error)
+
+	// RenderKey converts keys into the strings which are returned, e.g. to redact
+	// sensitive keys.
+	//
+	// If not specified, keys are formatted with fmt.Sprint.
+	RenderKey func(key interface{}) string
+
+	// RenderValue converts values into the strings which are returned, e.g. to redact
+	// sensitive values.
+	//
+	// If not specified, values are formatted with fmt.Sprint.
+	RenderValue func(value interface{}) string
+
+	// SampleSize is the maximum number of keys listed, when the request does not ask
+	// for a specific limit. If not specified, 100 keys are listed.
+	SampleSize int
+}
+
+// NewHandler instantiates a handler which serves the following endpoints:
+//
+//	GET  /                            HTML page summarizing all caches
+//	GET  /caches                      stats, size and configuration of all caches
+//	GET  /caches/{name}               stats, size and configuration of a cache
+//	GET  /caches/{name}/keys          sample of keys, limited by the limit parameter
+//	GET  /caches/{name}/entry         entry of the key parameter, without loading it
+//	POST /caches/{name}/invalidate    invalidates the key parameters, or all entries if all=true
+//	POST /caches/{name}/refresh       refreshes the key parameters
+//
+// All endpoints but the HTML page respond with JSON. To mount the handler under a
+// prefix, use http.StripPrefix.
+func NewHandler(options Options) http.Handler {
+	h := &handler{
+		registry:    options.Registry,
+		authorize:   options.Authorize,
+		parseKey:    options.ParseKey,
+		renderKey:   options.RenderKey,
+		renderValue: options.RenderValue,
+		sampleSize:  options.SampleSize,
+	}
+	if h.registry == nil {
+		h.registry = loadingcache.DefaultRegistry
+	}
+	if h.parseKey == nil {
+		h.parseKey = func(_ string, key string) (interface{}, error) {
+			return key, nil
+		}
+	}
+	if h.renderKey == nil {
+		h.renderKey = render
+	}
+	if h.renderValue == nil {
+		h.renderValue = render
+	}
+	if h.sampleSize <= 0 {
+		h.sampleSize = defaultSampleSize
+	}
+
+	h.mux = http.NewServeMux()
+	h.mux.HandleFunc("GET /{$}", h.servePage)
+	h.mux.HandleFunc("GET /caches", h.serveCaches)
+	h.mux.HandleFunc("GET /caches/{name}", h.withCache(h.serveCache))
+	h.mux.HandleFunc("GET /caches/{name}/keys", h.withCache(h.serveKeys))
+	h.mux.HandleFunc("GET /caches/{name}/entry", h.withCache(h.serveEntry))
+	h.mux.HandleFunc("POST /caches/{name}/invalidate", h.withCache(h.serveInvalidate))
+	h.mux.HandleFunc("POST /caches/{name}/refresh", h.withCache(h.serveRefresh))
+	return h
+}
+
+type handler struct {
+	registry    *loadingcache.Registry
+	authorize   func(r *http.Request) error
+	parseKey    func(cache string, key string) (interface{}, error)
+	renderKey   func(key interface{}) string
+	renderValue func(value interface{}) string
+	sampleSize  int
+
+	mux *http.ServeMux
+}
+
+func render(v interface{}) string {
+	return fmt.Sprint(v)
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize != nil {
+		if err := h.authorize(r); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// cacheHandlerFunc serves a request about the cache named in the path
+type cacheHandlerFunc func(w http.ResponseWriter, r *http.Request, name string, cache loadingcache.Cache)
+
+// withCache looks up the cache named in the path, responding with http.StatusNotFound
+// if it is not registered.
+func (h *handler) withCache(f cacheHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		cache, exists := h.registry.Lookup(name)
+		if !exists {
+			writeError(w, http.StatusNotFound, fmt.Errorf("cache %q is not registered", name))
+			return
+		}
+		f(w, r, name, cache)
+	}
+}
+
+func (h *handler) describeCaches() []loadingcache.CacheInfo {
+	caches := []loadingcache.CacheInfo{}
+	h.registry.Range(func(name string, cache loadingcache.Cache) bool {
+		caches = append(caches, loadingcache.DescribeCache(name, cache))
+		return true
+	})
+	return caches
+}
+
+func (h *handler) serveCaches(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.describeCaches())
+}
+
+func (h *handler) serveCache(w http.ResponseWriter, _ *http.Request, name string, cache loadingcache.Cache) {
+	writeJSON(w, http.StatusOK, loadingcache.DescribeCache(name, cache))
+}
+
+// keysInfo is a sample of the keys of a cache
+type keysInfo struct {
+	Keys []string `json:"keys"`
+
+	// Truncated is whether the cache holds more keys than listed
+	Truncated bool `json:"truncated"`
+}
+
+func (h *handler) serveKeys(w http.ResponseWriter, r *http.Request, _ string, cache loadingcache.Cache) {
+	limit := h.sampleSize
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = parsed
+	}
+	info := keysInfo{Keys: []string{}}
+	// Entries are listed in no particular order, so the first ones are a sample.
+	// The iteration stops once the limit is exceeded, so large caches are not copied.
+	cache.RangeEntries(func(entry loadingcache.Entry) bool {
+		if len(info.Keys) == limit {
+			info.Truncated = true
+			return false
+		}
+		info.Keys = append(info.Keys, h.renderKey(entry.Key))
+		return true
+	})
+	writeJSON(w, http.StatusOK, info)
+}
+
+// entryInfo describes an entry
+type entryInfo struct {
+	Key          string        `json:"key"`
+	Value        string        `json:"value"`
+	WriteTime    time.Time     `json:"write_time"`
+	AccessTime   time.Time     `json:"access_time"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	RemainingTTL time.Duration `json:"remaining_ttl_ns,omitempty"`
+	AccessCount  int64         `json:"access_count"`
+	Weight       int64         `json:"weight"`
+	Tags         []string      `json:"tags,omitempty"`
+}
+
+func (h *handler) serveEntry(w http.ResponseWriter, r *http.Request, name string, cache loadingcache.Cache) {
+	keys, ok := h.keys(w, r, name)
+	if !ok {
+		return
+	}
+	if len(keys) != 1 {
+		writeError(w, http.StatusBadRequest, errors.New("exactly one key must be provided"))
+		return
+	}
+	entry, err := cache.GetEntry(keys[0])
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	info := entryInfo{
+		Key:          h.renderKey(entry.Key),
+		Value:        h.renderValue(entry.Value),
+		WriteTime:    entry.WriteTime,
+		AccessTime:   entry.AccessTime,
+		RemainingTTL: entry.RemainingTTL,
+		AccessCount:  entry.AccessCount,
+		Weight:       entry.Weight,
+		Tags:         entry.Tags,
+	}
+	if !entry.ExpiresAt.IsZero() {
+		info.ExpiresAt = &entry.ExpiresAt
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (h *handler) serveInvalidate(w http.ResponseWriter, r *http.Request, name string, cache loadingcache.Cache) {
+	if r.FormValue("all") == "true" {
+		cache.InvalidateAll()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	keys, ok := h.keys(w, r, name)
+	if !ok {
+		return
+	}
+	if len(keys) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("at least one key, or all=true, must be provided"))
+		return
+	}
+	cache.Invalidate(keys[0], keys[1:]...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) serveRefresh(w http.ResponseWriter, r *http.Request, name string, cache loadingcache.Cache) {
+	keys, ok := h.keys(w, r, name)
+	if !ok {
+		return
+	}
+	if len(keys) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("at least one key must be provided"))
+		return
+	}
+	for _, key := range keys {
+		if err := cache.Refresh(r.Context(), key); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keys parses the key parameters of a request, responding with
+// http.StatusBadRequest if any is invalid.
+func (h *handler) keys(w http.ResponseWriter, r *http.Request, name string) ([]interface{}, bool) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	var keys []interface{}
+	for _, param := range r.Form["key"] {
+		key, err := h.parseKey(name, param)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid key %q: %w", param, err))
+			return nil, false
+		}
+		keys = append(keys, key)
+	}
+	return keys, true
+}
+
+// statusOf maps cache errors to response status codes
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, loadingcache.ErrKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, loadingcache.ErrClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+type errorInfo struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorInfo{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"percent": func(rate float64) string {
+		return strconv.FormatFloat(rate*100, 'f', 1, 64) + "%"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Caches</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Caches</h1>
+{{if .}}
+<table>
+<tr>
+<th>Name</th><th>Size</th><th>Weight</th><th>Max size</th>
+<th>Expire after write</th><th>Expire after read</th>
+<th>Hits</th><th>Misses</th><th>Hit rate</th><th>Loads</th><th>Load errors</th>
+<th>Evictions</th><th>Keys</th>
+</tr>
+{{range .}}
+<tr>
+<td><a href="caches/{{.Name}}">{{.Name}}</a></td>
+<td>{{.Size}}</td><td>{{.Weight}}</td><td>{{.Config.MaxSize}}</td>
+<td>{{.Config.ExpireAfterWrite}}</td><td>{{.Config.ExpireAfterRead}}</td>
+<td>{{.Stats.HitCount}}</td><td>{{.Stats.MissCount}}</td><td>{{percent .Stats.HitRate}}</td>
+<td>{{.Stats.LoadSuccessCount}}</td><td>{{.Stats.LoadErrorCount}}</td>
+<td>{{.Stats.EvictionCount}}</td>
+<td><a href="caches/{{.Name}}/keys">sample</a></td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>No caches are registered.</p>
+{{end}}
+</body>
+</html>
+`))
+
+func (h *handler) servePage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = pageTemplate.Execute(w, h.describeCaches())
+}
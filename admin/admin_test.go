@@ -0,0 +1,223 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/Hartimer/loadingcache/admin"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts a server exposing a registry with a users cache, keyed by ints,
+// and a sessions cache, keyed by strings
+func newTestServer(t *testing.T, options admin.Options) (*httptest.Server, *loadingcache.Registry) {
+	registry := loadingcache.NewRegistry()
+	t.Cleanup(registry.Close)
+	var loads int
+	registry.MustRegister("users", loadingcache.New(loadingcache.CacheOptions{
+		MaxSize:          10,
+		ExpireAfterWrite: time.Minute,
+		Load: func(key interface{}) (interface{}, error) {
+			if key.(int) < 0 {
+				return nil, errors.New("negative key")
+			}
+			loads++
+			return fmt.Sprintf("user %d v%d", key, loads), nil
+		},
+	}))
+	registry.MustRegister("sessions", loadingcache.New(loadingcache.CacheOptions{
+		ShardCount: 2,
+	}))
+
+	options.Registry = registry
+	options.ParseKey = func(cache string, key string) (interface{}, error) {
+		if cache == "users" {
+			return strconv.Atoi(key)
+		}
+		return key, nil
+	}
+	server := httptest.NewServer(admin.NewHandler(options))
+	t.Cleanup(server.Close)
+	return server, registry
+}
+
+func getJSON(t *testing.T, server *httptest.Server, path string, status int, v interface{}) {
+	resp, err := http.Get(server.URL + path)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, status, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+func post(t *testing.T, server *httptest.Server, path string, form url.Values) int {
+	resp, err := http.PostForm(server.URL+path, form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestCaches(t *testing.T) {
+	server, registry := newTestServer(t, admin.Options{})
+	users, _ := registry.Lookup("users")
+	_, err := users.Get(1)
+	require.NoError(t, err)
+	_, err = users.Get(1)
+	require.NoError(t, err)
+
+	var caches []map[string]interface{}
+	getJSON(t, server, "/caches", http.StatusOK, &caches)
+	require.Len(t, caches, 2)
+	require.Equal(t, "sessions", caches[0]["name"])
+	require.Equal(t, "users", caches[1]["name"])
+
+	var cache struct {
+		Name   string `json:"name"`
+		Size   int    `json:"size"`
+		Config struct {
+			MaxSize          int32         `json:"max_size"`
+			ExpireAfterWrite time.Duration `json:"expire_after_write_ns"`
+		} `json:"config"`
+		Stats loadingcache.StatsSnapshot `json:"stats"`
+	}
+	getJSON(t, server, "/caches/users", http.StatusOK, &cache)
+	require.Equal(t, "users", cache.Name)
+	require.Equal(t, 1, cache.Size)
+	require.Equal(t, int32(10), cache.Config.MaxSize)
+	require.Equal(t, time.Minute, cache.Config.ExpireAfterWrite)
+	require.Equal(t, int64(1), cache.Stats.HitCount())
+	require.Equal(t, int64(1), cache.Stats.LoadSuccessCount())
+
+	var errInfo map[string]string
+	getJSON(t, server, "/caches/missing", http.StatusNotFound, &errInfo)
+	require.Equal(t, `cache "missing" is not registered`, errInfo["error"])
+}
+
+func TestKeysAndEntries(t *testing.T) {
+	server, registry := newTestServer(t, admin.Options{
+		RenderValue: func(value interface{}) string {
+			return strings.ToUpper(value.(string))
+		},
+	})
+	sessions, _ := registry.Lookup("sessions")
+	for i := 0; i < 5; i++ {
+		sessions.Put(fmt.Sprint(i), "session")
+	}
+
+	var keys struct {
+		Keys      []string `json:"keys"`
+		Truncated bool     `json:"truncated"`
+	}
+	getJSON(t, server, "/caches/sessions/keys", http.StatusOK, &keys)
+	require.Len(t, keys.Keys, 5)
+	require.False(t, keys.Truncated)
+	getJSON(t, server, "/caches/sessions/keys?limit=2", http.StatusOK, &keys)
+	require.Len(t, keys.Keys, 2)
+	require.True(t, keys.Truncated)
+
+	var errInfo map[string]string
+	getJSON(t, server, "/caches/sessions/keys?limit=none", http.StatusBadRequest, &errInfo)
+
+	var entry map[string]interface{}
+	getJSON(t, server, "/caches/sessions/entry?key=3", http.StatusOK, &entry)
+	require.Equal(t, "3", entry["key"])
+	require.Equal(t, "SESSION", entry["value"])
+	require.Nil(t, entry["expires_at"])
+
+	// Looking up an entry never loads it
+	getJSON(t, server, "/caches/users/entry?key=1", http.StatusNotFound, &errInfo)
+	getJSON(t, server, "/caches/users/entry?key=a", http.StatusBadRequest, &errInfo)
+	getJSON(t, server, "/caches/users/entry", http.StatusBadRequest, &errInfo)
+}
+
+func TestInvalidate(t *testing.T) {
+	server, registry := newTestServer(t, admin.Options{})
+	sessions, _ := registry.Lookup("sessions")
+	for _, key := range []string{"a", "b", "c"} {
+		sessions.Put(key, "session")
+	}
+
+	require.Equal(t, http.StatusNoContent, post(t, server, "/caches/sessions/invalidate", url.Values{
+		"key": {"a", "b"},
+	}))
+	require.Equal(t, []interface{}{"c"}, sessions.Keys())
+
+	require.Equal(t, http.StatusBadRequest, post(t, server, "/caches/sessions/invalidate", nil))
+	require.Equal(t, http.StatusNoContent, post(t, server, "/caches/sessions/invalidate", url.Values{
+		"all": {"true"},
+	}))
+	require.Equal(t, 0, sessions.Len())
+
+	// Mutations are only allowed with POST
+	resp, err := http.Get(server.URL + "/caches/sessions/invalidate?all=true")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRefresh(t *testing.T) {
+	server, registry := newTestServer(t, admin.Options{})
+	users, _ := registry.Lookup("users")
+	val, err := users.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, "user 1 v1", val)
+
+	require.Equal(t, http.StatusNoContent, post(t, server, "/caches/users/refresh", url.Values{
+		"key": {"1"},
+	}))
+	val, err = users.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, "user 1 v2", val)
+
+	require.Equal(t, http.StatusBadGateway, post(t, server, "/caches/users/refresh", url.Values{
+		"key": {"-1"},
+	}))
+	require.Equal(t, http.StatusBadRequest, post(t, server, "/caches/users/refresh", nil))
+
+	// Caches without a loading function cannot be refreshed
+	require.Equal(t, http.StatusNotFound, post(t, server, "/caches/sessions/refresh", url.Values{
+		"key": {"a"},
+	}))
+}
+
+func TestAuthorize(t *testing.T) {
+	server, _ := newTestServer(t, admin.Options{
+		Authorize: func(r *http.Request) error {
+			if r.Method != http.MethodGet {
+				return errors.New("read only")
+			}
+			return nil
+		},
+	})
+
+	var caches []map[string]interface{}
+	getJSON(t, server, "/caches", http.StatusOK, &caches)
+	require.Equal(t, http.StatusForbidden, post(t, server, "/caches/sessions/invalidate", url.Values{
+		"all": {"true"},
+	}))
+}
+
+func TestPage(t *testing.T) {
+	server, _ := newTestServer(t, admin.Options{})
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `<a href="caches/users">users</a>`)
+	require.Contains(t, string(body), `<a href="caches/sessions">sessions</a>`)
+}
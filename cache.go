@@ -6,23 +6,25 @@
 //
 // This project is heavily inspired by Guava Cache (https://github.com/google/guava/wiki/CachesExplained).
 //
-// All errors are wrapped by github.com/pkg/errors.Wrap. If you which to check
-// the type of it, please use github.com/pkg/errors.Is.
+// Errors returned by the cache support the standard library's errors.Is and
+// errors.As. Failures to load a value are reported as a *LoadError, which wraps
+// the cause of the failure.
 package loadingcache
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Hartimer/loadingcache/internal/stats"
 	"github.com/benbjohnson/clock"
-	"github.com/pkg/errors"
 )
 
-// ErrKeyNotFound represents an error indicating that the key was not found
-var ErrKeyNotFound error = errors.New("Key not found")
-
 // RemovalReason is an enum describing the causes for an entry to
 // be removed from the cache.
 type RemovalReason string
@@ -61,6 +63,10 @@ type Cache interface {
 	// the provided key, loadingcache.ErrKeyNotFound is returned.
 	Get(key interface{}) (interface{}, error)
 
+	// GetContext is like Get, but the context is passed to a CacheOptions.LoadContext
+	// loading function, and the lookup is reported to any Trace attached with WithTrace.
+	GetContext(ctx context.Context, key interface{}) (interface{}, error)
+
 	// Put adds a value to the cache identified by a key.
 	// If a value already exists associated with that key, it
 	// is replaced.
@@ -72,11 +78,85 @@ type Cache interface {
 	// InvalidateAll invalidates all keys
 	InvalidateAll()
 
-	// Close cleans up any resources used by the cache
+	// Refresh loads a new value for a key, replacing the cached one, if any.
+	// If loading fails, the cached value is kept and the error is returned.
+	// If the cache has no loading function, loadingcache.ErrKeyNotFound is returned.
+	//
+	// Like GetContext, the context is passed to the loading function, and the
+	// load is reported to any Trace attached with WithTrace.
+	Refresh(ctx context.Context, key interface{}) error
+
+	// Close cleans up any resources used by the cache.
+	//
+	// It is equivalent to calling Shutdown with a context that never ends,
+	// and is safe to call multiple times.
 	Close()
 
+	// Shutdown closes the cache and waits for in-flight operations,
+	// such as loads and removal notifications, and background tasks to complete.
+	// If the context ends first, its error is returned, while in-flight
+	// operations carry on in the background.
+	//
+	// Once closed, Get returns ErrClosed, while other operations are noops.
+	Shutdown(ctx context.Context) error
+
 	// Stats returns the curret stats
 	Stats() Stats
+
+	// StatsSnapshot returns an immutable copy of the current stats.
+	StatsSnapshot() StatsSnapshot
+
+	// ResetStats clears all the stats recorded so far, including windowed ones.
+	ResetStats()
+
+	// Policy allows inspecting and changing the configuration of the cache at runtime
+	Policy() Policy
+
+	// GetEntry returns the entry associated with a given key, along with its metadata.
+	// If no entry exists for the provided key, or it has expired, loadingcache.ErrKeyNotFound
+	// is returned.
+	//
+	// Unlike Get, it never loads values, and it does not affect the entry's
+	// access time nor the cache stats.
+	GetEntry(key interface{}) (Entry, error)
+
+	// RangeEntries calls f sequentially for each entry in the cache, along with its metadata.
+	// If f returns false, the iteration stops. Expired entries are skipped.
+	//
+	// No lock is held while iterating, so f may safely call methods on the cache, and
+	// stopping early avoids visiting the remaining entries. The iteration does not wait
+	// for concurrent writes and loads, so the entries are not a snapshot at a point
+	// in time: changes made while iterating, including by f, may or may not be reflected.
+	RangeEntries(f func(Entry) bool)
+
+	// Len returns the number of entries in the cache, excluding expired ones.
+	//
+	// Expired entries are checked one by one, so this is not a constant time operation.
+	// Like RangeEntries, it does not wait for concurrent writes and loads, which may
+	// or may not be counted.
+	Len() int
+
+	// Weight returns the total weight of the entries in the cache, excluding expired ones.
+	// Entries count as a weight of 1, unless loaded with a LoadResult.Weight.
+	//
+	// Like Len, this is not a constant time operation.
+	Weight() int64
+
+	// Keys returns the keys of all entries in the cache, excluding expired ones.
+	// It has the same consistency guarantees as RangeEntries.
+	Keys() []interface{}
+
+	// Range calls f sequentially for each key and value in the cache.
+	// If f returns false, the iteration stops.
+	// It has the same consistency guarantees as RangeEntries.
+	Range(f func(key interface{}, value interface{}) bool)
+
+	// Snapshot returns a copy of all keys and values in the cache,
+	// excluding expired ones.
+	//
+	// It has the same consistency guarantees as RangeEntries, so the copy is not
+	// a snapshot at a point in time: writes made while copying may or may not be reflected.
+	Snapshot() map[interface{}]interface{}
 }
 
 // CacheOptions available options to initialize the cache
@@ -97,6 +177,21 @@ type CacheOptions struct {
 	// Load configures a loading function
 	Load LoadFunc
 
+	// LoadWithResult configures a loading function which, besides the value,
+	// returns metadata controlling how the value is cached.
+	//
+	// Only one of Load, LoadWithResult and LoadContext may be set.
+	LoadWithResult LoadWithResultFunc
+
+	// LoadContext configures a loading function which receives the context passed
+	// to GetContext, e.g. to propagate deadlines or tracing spans. Like LoadWithResult,
+	// it returns metadata controlling how the value is cached.
+	//
+	// Lookups with Get use context.Background().
+	//
+	// Only one of Load, LoadWithResult and LoadContext may be set.
+	LoadContext LoadContextFunc
+
 	// MaxSize limits the number of entries allowed in the cache.
 	// If the limit is achieved, an eviction process will take place,
 	// this means that eviction policies will be executed such as write
@@ -107,17 +202,27 @@ type CacheOptions struct {
 	// meaning that the overall capacity will be MaxSize * ShardCount.
 	MaxSize int32
 
+	// MaxWeight limits the total weight of the entries allowed in the cache.
+	// Entries have a weight of 1, unless loaded with a different weight (see LoadResult).
+	// If the limit is achieved, entries are evicted in the same way as with MaxSize.
+	//
+	// If the cache is sharded, MaxWeight is applied to each shard.
+	MaxWeight int64
+
 	// RemovalListeners configures a removal listeners
 	RemovalListeners []RemovalListener
 
 	// ShardCount indicates how many shards will be used by the cache.
 	// This allows some degree of parallelism in read and writing to the cache.
 	//
-	// If the shard count is greater than 1, then HashCodeFunc must be provided
-	// otherwise the constructor will panic.
+	// If the shard count is greater than 1, keys are assigned to shards based on
+	// HashCodeFunc.
 	ShardCount int
 
 	// HashCodeFunc is a function that produces a hashcode of the key.
+	// Negative hash codes are allowed.
+	//
+	// If not specified, DefaultHashCode is used.
 	//
 	// See https://docs.oracle.com/en/java/javase/15/docs/api/java.base/java/lang/Object.html#hashCode()
 	// for best practices surrounding hash code functions.
@@ -130,90 +235,322 @@ type CacheOptions struct {
 	// The background go routine runs with the provided frequency.
 	// To avoid go routine leaks, use the close function when you're done with the cache.
 	BackgroundEvictFrequency time.Duration
+
+	// TTLJitter randomly brings forward the write expiry of each entry by up to
+	// the given duration. This spreads out expirations of entries which were
+	// written at the same time (e.g. when warming up the cache), avoiding a
+	// synchronized wave of reloads.
+	//
+	// Only one of TTLJitter and TTLJitterFraction may be set.
+	TTLJitter time.Duration
+
+	// TTLJitterFraction is similar to TTLJitter, but the maximum jitter is
+	// expressed as a fraction of ExpireAfterWrite. It must be between 0 and 1.
+	TTLJitterFraction float64
+
+	// JitterSource returns a pseudo-random number in the half-open interval [0.0,1.0),
+	// which is used to compute each entry's jitter.
+	//
+	// If not specified, math/rand.Float64 is used. This is useful for testing,
+	// where determinism is important.
+	JitterSource func() float64
+
+	// ErrorHandler is called with errors which cannot be returned to a caller,
+	// such as a *ListenerPanicError when a removal listener panics.
+	//
+	// If not specified, such errors are dropped.
+	ErrorHandler func(error)
+
+	// DisableStats turns off stats recording entirely, which makes the hot path
+	// as cheap as possible. When set, Stats always reports zero values.
+	DisableStats bool
+
+	// StatsRecorder receives the stats events instead of the built-in stats.
+	// It is shared by all the shards of a sharded cache.
+	//
+	// When set, Stats always reports zero values, since the events are only sent
+	// to the recorder. If not specified, the built-in stats are used.
+	StatsRecorder StatsRecorder
+
+	// Hooks are called on cache events. See Hooks for details.
+	Hooks Hooks
+
+	// Middlewares decorate the Get, Put, Invalidate and Load operations of the cache.
+	// The first middleware is the outermost, i.e. it is called first.
+	Middlewares []Middleware
+
+	// Logger logs loads, load errors, evictions, background eviction sweeps and
+	// lifecycle events. Events of sharded caches include the shard index.
+	//
+	// Evictions are logged while holding the cache's write lock, so the logger's
+	// handler should be fast. If not specified, nothing is logged.
+	Logger *slog.Logger
+
+	// LogLevels configures the level of each logged event. See LogLevels for the defaults.
+	LogLevels LogLevels
+
+	// KeyRenderer converts keys to the strings which are logged, e.g. to redact
+	// sensitive keys. If not specified, keys are logged as they are.
+	KeyRenderer func(key interface{}) string
 }
 
-func (c CacheOptions) expiresAfterRead() bool {
-	return c.ExpireAfterRead > 0
+func (c CacheOptions) hasTTLJitter() bool {
+	return c.TTLJitter > 0 || c.TTLJitterFraction > 0
 }
 
-func (c CacheOptions) expiresAfterWrite() bool {
-	return c.ExpireAfterWrite > 0
+// validate checks that the options are consistent
+func (c CacheOptions) validate() error {
+	switch {
+	case c.ShardCount < 0:
+		return invalidOptions("shard count must be non-negative")
+	case c.TTLJitter < 0:
+		return invalidOptions("ttl jitter must be non-negative")
+	case c.TTLJitterFraction < 0 || c.TTLJitterFraction > 1:
+		return invalidOptions("ttl jitter fraction must be between 0 and 1")
+	case c.TTLJitter > 0 && c.TTLJitterFraction > 0:
+		return invalidOptions("cannot have both ttl jitter and ttl jitter fraction")
+	case c.loaderCount() > 1:
+		return invalidOptions("cannot have more than one load function")
+	case c.MaxWeight < 0:
+		return invalidOptions("max weight must be non-negative")
+	}
+	for _, middleware := range c.Middlewares {
+		if middleware == nil {
+			return invalidOptions("middleware must not be nil")
+		}
+	}
+	return nil
+}
+
+// loaderCount returns how many loading functions are configured
+func (c CacheOptions) loaderCount() int {
+	var count int
+	for _, configured := range []bool{c.Load != nil, c.LoadWithResult != nil, c.LoadContext != nil} {
+		if configured {
+			count++
+		}
+	}
+	return count
 }
 
-// CacheOption describes an option that can configure the cache
-type CacheOption func(Cache)
+// loader returns the configured loading function, if any,
+// in the form of a LoadContextFunc.
+func (c CacheOptions) loader() LoadContextFunc {
+	switch {
+	case c.LoadContext != nil:
+		return c.LoadContext
+	case c.LoadWithResult != nil:
+		return func(_ context.Context, key interface{}) (LoadResult, error) {
+			return c.LoadWithResult(key)
+		}
+	case c.Load != nil:
+		return func(_ context.Context, key interface{}) (LoadResult, error) {
+			val, err := c.Load(key)
+			return LoadResult{Value: val}, err
+		}
+	default:
+		return nil
+	}
+}
 
 // LoadFunc represents a function that given a key, it returns a value or an error.
 type LoadFunc func(interface{}) (interface{}, error)
 
+// LoadResult is the outcome of a LoadWithResultFunc. Besides the loaded value,
+// it allows the loading function to control how the value is cached.
+type LoadResult struct {
+	// Value is the loaded value
+	Value interface{}
+
+	// TTL overrides ExpireAfterWrite for this entry.
+	// If not specified, ExpireAfterWrite applies.
+	TTL time.Duration
+
+	// Weight is the weight of the entry, which counts towards MaxWeight.
+	// If not specified, the entry has a weight of 1.
+	Weight int64
+
+	// NoCache indicates that the value should be returned to the caller
+	// but not cached, e.g. for partial or degraded responses.
+	NoCache bool
+
+	// Tags are arbitrary labels associated with the entry.
+	Tags []string
+}
+
+// LoadWithResultFunc represents a function that given a key, it returns
+// a LoadResult or an error.
+type LoadWithResultFunc func(interface{}) (LoadResult, error)
+
+// LoadContextFunc represents a function that given a context and a key, it returns
+// a LoadResult or an error.
+type LoadContextFunc func(context.Context, interface{}) (LoadResult, error)
+
+// cacheEntry holds a value and its metadata.
+//
+// Entries are never modified once stored, apart from fields which
+// are accessed atomically, so they can be read without any locks.
 type cacheEntry struct {
 	key       interface{}
 	value     interface{}
-	lastRead  time.Time
 	lastWrite time.Time
+
+	// lastRead is the time of the last read, in unix nanoseconds
+	lastRead atomic.Int64
+
+	// jitter is a random factor in [0, 1) which is used
+	// to compute the effective write expiry of the entry.
+	jitter float64
+
+	// ttl overrides the write expiry of the cache, if positive.
+	ttl time.Duration
+
+	weight int64
+	tags   []string
+
+	// accessCount is the number of times the entry was read
+	accessCount atomic.Int64
+}
+
+func (e *cacheEntry) lastReadTime() time.Time {
+	return time.Unix(0, e.lastRead.Load())
 }
 
-// New instantiates a new cache
+// New instantiates a new cache.
+//
+// It panics if the options are invalid. See NewWithOptions for
+// an alternative which returns an error instead.
 func New(options CacheOptions) Cache {
+	cache, err := newCache(options)
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// newCache validates the options and instantiates a new cache
+func newCache(options CacheOptions) (Cache, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
 	if options.Clock == nil {
 		options.Clock = clock.New()
 	}
 
-	if options.ShardCount < 0 {
-		panic("shard count must be non-negative")
+	if options.JitterSource == nil {
+		options.JitterSource = rand.Float64
 	}
 
-	switch options.ShardCount {
-	case 0, 1:
-		c := &genericCache{
-			CacheOptions: options,
-			data:         map[interface{}]*cacheEntry{},
-			done:         make(chan struct{}),
-			stats:        &stats.InternalStats{},
-		}
-		if options.BackgroundEvictFrequency > 0 {
-			c.backgroundWg.Add(1)
-			go c.runBackgroundEvict()
+	if options.HashCodeFunc == nil {
+		options.HashCodeFunc = DefaultHashCode
+	}
+
+	var cache Cache
+	if len(options.Middlewares) > 0 {
+		cache = newMiddlewareCache(options)
+	} else {
+		cache = newBaseCache(options)
+	}
+	newCacheLogger(options).created(options)
+	return cache, nil
+}
+
+// newBaseCache builds a generic or sharded cache from validated options.
+func newBaseCache(options CacheOptions) Cache {
+	if options.ShardCount <= 1 {
+		return newGenericCache(options)
+	}
+
+	singleShardOptions := options
+	singleShardOptions.ShardCount = 1
+	s := &shardedCache{
+		CacheOptions: options,
+		shards:       make([]Cache, options.ShardCount),
+		logger:       newCacheLogger(options),
+	}
+	for i := 0; i < options.ShardCount; i++ {
+		shard := newGenericCache(singleShardOptions)
+		shard.logger = shard.logger.forShard(i)
+		s.shards[i] = shard
+	}
+	return s
+}
+
+func newGenericCache(options CacheOptions) *genericCache {
+	c := &genericCache{
+		CacheOptions: options,
+		loader:       options.loader(),
+		done:         make(chan struct{}),
+		lifecycle:    newLifecycle(),
+		logger:       newCacheLogger(options),
+	}
+	switch options.StatsRecorder.(type) {
+	case nil:
+		if !options.DisableStats {
+			c.stats = &stats.InternalStats{}
 		}
-		return c
+	case NoopStatsRecorder, *NoopStatsRecorder:
+		// Leaving both unset is the cheapest way to discard stats
 	default:
-		if options.HashCodeFunc == nil {
-			panic("cannot have a sharded cache without a hashcode function")
-		}
-		singleShardOptions := options
-		singleShardOptions.ShardCount = 1
-		s := &shardedCache{
-			CacheOptions: options,
-			shards:       make([]Cache, options.ShardCount),
+		if !options.DisableStats {
+			c.recorder = options.StatsRecorder
 		}
-		for i := 0; i < options.ShardCount; i++ {
-			s.shards[i] = New(singleShardOptions)
-		}
-		return s
 	}
+	c.maxSize.Store(options.MaxSize)
+	c.expireAfterWrite.Store(int64(options.ExpireAfterWrite))
+	c.expireAfterRead.Store(int64(options.ExpireAfterRead))
+	if options.BackgroundEvictFrequency > 0 {
+		// The background go routine is tracked as an in-flight operation,
+		// so shutting down waits for it to complete.
+		c.lifecycle.begin()
+		go c.runBackgroundEvict()
+	}
+	return c
 }
 
 type shardedCache struct {
 	CacheOptions
 	shards []Cache
+	logger *cacheLogger
+
+	// shutDown is set once the cache was successfully shut down
+	shutDown atomic.Bool
+}
+
+// shard returns the shard responsible for a given key
+func (s *shardedCache) shard(key interface{}) Cache {
+	index := s.HashCodeFunc(key) % len(s.shards)
+	if index < 0 {
+		// Hash codes may be negative, in which case so is the remainder
+		index += len(s.shards)
+	}
+	return s.shards[index]
 }
 
 func (s *shardedCache) Get(key interface{}) (interface{}, error) {
-	val, err := s.shards[s.HashCodeFunc(key)%len(s.shards)].Get(key)
-	return val, errors.Wrap(err, "")
+	return s.shard(key).Get(key)
+}
+
+func (s *shardedCache) GetContext(ctx context.Context, key interface{}) (interface{}, error) {
+	return s.shard(key).GetContext(ctx, key)
 }
 
 func (s *shardedCache) Put(key interface{}, value interface{}) {
-	s.shards[s.HashCodeFunc(key)%len(s.shards)].Put(key, value)
+	s.shard(key).Put(key, value)
 }
 
 func (s *shardedCache) Invalidate(key interface{}, keys ...interface{}) {
-	s.shards[s.HashCodeFunc(key)%len(s.shards)].Invalidate(key)
+	s.shard(key).Invalidate(key)
 	for _, k := range keys {
-		s.shards[s.HashCodeFunc(k)%len(s.shards)].Invalidate(k)
+		s.shard(k).Invalidate(k)
 	}
 }
 
+func (s *shardedCache) Refresh(ctx context.Context, key interface{}) error {
+	return s.shard(key).Refresh(ctx, key)
+}
+
 func (s *shardedCache) InvalidateAll() {
 	for _, shard := range s.shards {
 		shard.InvalidateAll()
@@ -221,110 +558,325 @@ func (s *shardedCache) InvalidateAll() {
 }
 
 func (s *shardedCache) Close() {
-	for _, shard := range s.shards {
-		shard.Close()
+	_ = s.Shutdown(context.Background())
+}
+
+// Shutdown closes all shards in parallel
+func (s *shardedCache) Shutdown(ctx context.Context) error {
+	shardErrs := make([]error, len(s.shards))
+	var shutdownWg sync.WaitGroup
+	shutdownWg.Add(len(s.shards))
+	for i := range s.shards {
+		i := i
+		go func() {
+			defer shutdownWg.Done()
+			shardErrs[i] = s.shards[i].Shutdown(ctx)
+		}()
+	}
+	shutdownWg.Wait()
+	var err error
+	for _, shardErr := range shardErrs {
+		if shardErr != nil {
+			err = shardErr
+			break
+		}
 	}
+	if err != nil || !s.shutDown.Swap(true) {
+		s.logger.shutDown(err)
+	}
+	return err
 }
 
 func (s *shardedCache) Stats() Stats {
-	statsSum := &stats.InternalStats{}
+	return s.StatsSnapshot()
+}
+
+func (s *shardedCache) ResetStats() {
 	for _, shard := range s.shards {
-		switch typedCache := shard.(type) {
-		case *genericCache:
-			statsSum = statsSum.Add(typedCache.stats)
-		default:
-			panic(fmt.Sprintf("unsupported cache type %T", shard))
-		}
+		shard.ResetStats()
+	}
+}
+
+func (s *shardedCache) StatsSnapshot() StatsSnapshot {
+	var statsSum StatsSnapshot
+	for _, shard := range s.shards {
+		statsSum = statsSum.Plus(shard.StatsSnapshot())
 	}
 	return statsSum
 }
 
+func (s *shardedCache) GetEntry(key interface{}) (Entry, error) {
+	return s.shard(key).GetEntry(key)
+}
+
+func (s *shardedCache) RangeEntries(f func(Entry) bool) {
+	for _, shard := range s.shards {
+		keepGoing := true
+		shard.RangeEntries(func(entry Entry) bool {
+			keepGoing = f(entry)
+			return keepGoing
+		})
+		if !keepGoing {
+			return
+		}
+	}
+}
+
+func (s *shardedCache) Len() int {
+	var length int
+	for _, shard := range s.shards {
+		length += shard.Len()
+	}
+	return length
+}
+
+func (s *shardedCache) Weight() int64 {
+	var weight int64
+	for _, shard := range s.shards {
+		weight += shard.Weight()
+	}
+	return weight
+}
+
+func (s *shardedCache) Keys() []interface{} {
+	return keys(s)
+}
+
+func (s *shardedCache) Range(f func(key interface{}, value interface{}) bool) {
+	rangeValues(s, f)
+}
+
+func (s *shardedCache) Snapshot() map[interface{}]interface{} {
+	return snapshot(s)
+}
+
+func (s *shardedCache) Policy() Policy {
+	return shardedPolicy{cache: s}
+}
+
 // genericCache is an implementation of a cache where keys and values are
 // of type interface{}
 type genericCache struct {
 	CacheOptions
 
-	data     map[interface{}]*cacheEntry
-	dataLock sync.RWMutex
+	loader LoadContextFunc
+
+	// data maps keys to *cacheEntry. Reads do not take any locks,
+	// while writes are serialized by writeLock.
+	data      sync.Map
+	writeLock sync.Mutex
+
+	// size is the number of entries, guarded by writeLock
+	size int32
+
+	// totalWeight is the sum of the weights of all entries, guarded by writeLock
+	totalWeight int64
 
-	done         chan struct{}
-	backgroundWg sync.WaitGroup
+	// The following settings may be changed at runtime through the policy,
+	// so they are accessed atomically instead of through CacheOptions.
+	maxSize          atomic.Int32
+	expireAfterWrite atomic.Int64
+	expireAfterRead  atomic.Int64
 
-	stats *stats.InternalStats
+	done      chan struct{}
+	lifecycle *lifecycle
+
+	stats    *stats.InternalStats
+	recorder StatsRecorder
+	logger   *cacheLogger
 }
 
-func (g *genericCache) isExpired(entry *cacheEntry) bool {
-	if g.expiresAfterRead() && entry.lastRead.Add(g.ExpireAfterRead).Before(g.Clock.Now()) {
+func (g *genericCache) isExpired(entry *cacheEntry, now time.Time) bool {
+	readTTL := time.Duration(g.expireAfterRead.Load())
+	if readTTL > 0 && entry.lastReadTime().Add(readTTL).Before(now) {
 		return true
 	}
-	if g.expiresAfterWrite() && entry.lastWrite.Add(g.ExpireAfterWrite).Before(g.Clock.Now()) {
+	if ttl, expires := g.writeTTL(entry); expires && entry.lastWrite.Add(ttl).Before(now) {
 		return true
 	}
 	return false
 }
 
-func (g *genericCache) Get(key interface{}) (interface{}, error) {
-	g.dataLock.RLock()
-	entry, exists := g.data[key]
+// writeTTL returns the effective write expiry of an entry, taking into account
+// its own TTL and jitter factor, and whether the entry expires at all.
+func (g *genericCache) writeTTL(entry *cacheEntry) (time.Duration, bool) {
+	ttl := entry.ttl
+	if ttl <= 0 {
+		ttl = time.Duration(g.expireAfterWrite.Load())
+	}
+	if ttl <= 0 {
+		return 0, false
+	}
+	maxJitter := g.TTLJitter
+	if g.TTLJitterFraction > 0 {
+		maxJitter = time.Duration(float64(ttl) * g.TTLJitterFraction)
+	}
+	if maxJitter > ttl {
+		maxJitter = ttl
+	}
+	return ttl - time.Duration(float64(maxJitter)*entry.jitter), true
+}
+
+// lookup returns the entry associated with a key, without taking any locks
+func (g *genericCache) lookup(key interface{}) (*cacheEntry, bool) {
+	val, exists := g.data.Load(key)
 	if !exists {
-		g.dataLock.RUnlock()
-		val, err := g.load(key)
-		return val, errors.Wrap(err, "")
+		return nil, false
 	}
-	// Create a copy of the value to return to avoid concurrent updates
-	toReturn := entry.value
-	g.dataLock.RUnlock()
+	return val.(*cacheEntry), true
+}
+
+// rangeData calls f for each entry, without taking any locks.
+// If f returns false, the iteration stops.
+func (g *genericCache) rangeData(f func(entry *cacheEntry) bool) {
+	g.data.Range(func(_, val interface{}) bool {
+		return f(val.(*cacheEntry))
+	})
+}
 
-	if g.isExpired(entry) {
-		g.concurrentEvict(key, RemovalReasonExpired)
-		val, err := g.load(key)
-		return val, errors.Wrap(err, "")
+func (g *genericCache) Get(key interface{}) (interface{}, error) {
+	return g.get(context.Background(), key, nil)
+}
+
+func (g *genericCache) GetContext(ctx context.Context, key interface{}) (interface{}, error) {
+	return g.get(ctx, key, ContextTrace(ctx))
+}
+
+func (g *genericCache) get(ctx context.Context, key interface{}, trace *Trace) (interface{}, error) {
+	// Hits are not tracked as in-flight operations, since that would mean
+	// contending on a shared counter. They do not need to be waited on
+	// when shutting down anyway.
+	if g.lifecycle.isClosed() {
+		return nil, ErrClosed
+	}
+	now := g.Clock.Now()
+	if entry, exists := g.lookup(key); exists && !g.isExpired(entry, now) {
+		g.hit(entry, now)
+		trace.hit(key)
+		return entry.value, nil
+	}
+
+	if !g.lifecycle.begin() {
+		return nil, ErrClosed
 	}
-	// It is possible that this will race. It will only be a problem
-	// if the expiry thresholds have to be respected with a high
-	// degree of precision (which is subjective).
-	entry.lastRead = g.Clock.Now()
-	g.stats.Hit()
-	return toReturn, nil
+	defer g.lifecycle.end()
+	return g.load(ctx, key, trace)
 }
 
-func (g *genericCache) load(key interface{}) (interface{}, error) {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
+// hit records a read of an entry. It is safe to call concurrently.
+func (g *genericCache) hit(entry *cacheEntry, now time.Time) {
+	entry.lastRead.Store(now.UnixNano())
+	entry.accessCount.Add(1)
+	g.recordHit(now)
+	g.Hooks.hit(entry.key, entry.value)
+}
+
+func (g *genericCache) load(ctx context.Context, key interface{}, trace *Trace) (interface{}, error) {
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
 
 	// It is possible that another call loaded the value for this key.
 	// Let's do a double check if that was the case, since we have
 	// the lock.
-	if val, exists := g.data[key]; exists {
-		g.stats.Hit()
-		return val, nil
-	} else if g.Load == nil {
-		g.stats.Miss()
-		return nil, errors.Wrap(ErrKeyNotFound, "")
+	if entry, exists := g.lookup(key); exists {
+		if now := g.Clock.Now(); !g.isExpired(entry, now) {
+			g.hit(entry, now)
+			trace.sharedLoad(key)
+			return entry.value, nil
+		}
+		g.evict(key, RemovalReasonExpired)
+	}
+	g.Hooks.miss(key)
+	if g.loader == nil {
+		g.recordMiss()
+		trace.miss(key)
+		return nil, ErrKeyNotFound
 	}
 
+	result, err := g.loadResult(ctx, key, trace)
+	if err != nil {
+		return nil, err
+	}
+	if !result.NoCache {
+		g.internalPut(key, result)
+	}
+	return result.Value, nil
+}
+
+// loadResult calls the loading function, recording the outcome and validating the result.
+// It does not handle any synchronization, leaving that to the caller.
+func (g *genericCache) loadResult(ctx context.Context, key interface{}, trace *Trace) (LoadResult, error) {
+	trace.loadStart(key)
+	g.Hooks.loadStart(key)
 	loadStartTime := g.Clock.Now()
-	val, err := g.Load(key)
+	result, err := g.callLoader(withoutTrace(ctx), key)
+	loadEndTime := g.Clock.Now()
 	if err != nil {
-		g.stats.LoadError()
-		return nil, errors.Wrapf(err, "failed to load key %v", key)
+		loadErr := &LoadError{Key: key, Attempts: 1, Err: err}
+		g.recordLoadError(loadEndTime, loadEndTime.Sub(loadStartTime))
+		g.logger.loadFailed(key, loadErr, loadEndTime.Sub(loadStartTime))
+		trace.loadDone(key, loadErr)
+		g.Hooks.loadEnd(key, nil, loadErr, loadEndTime.Sub(loadStartTime))
+		return LoadResult{}, loadErr
 	}
-	g.stats.LoadTime(g.Clock.Now().Sub(loadStartTime))
-	g.stats.LoadSuccess()
-	g.internalPut(key, val)
-	return val, nil
+	if result.Weight <= 0 {
+		result.Weight = 1
+	}
+	if g.MaxWeight > 0 && result.Weight > g.MaxWeight {
+		loadErr := &LoadError{
+			Key:      key,
+			Attempts: 1,
+			Err:      fmt.Errorf("weight %d exceeds max weight %d: %w", result.Weight, g.MaxWeight, ErrCapacityExceeded),
+		}
+		g.recordLoadError(loadEndTime, loadEndTime.Sub(loadStartTime))
+		g.logger.loadFailed(key, loadErr, loadEndTime.Sub(loadStartTime))
+		trace.loadDone(key, loadErr)
+		g.Hooks.loadEnd(key, nil, loadErr, loadEndTime.Sub(loadStartTime))
+		return LoadResult{}, loadErr
+	}
+	g.recordLoadSuccess(loadEndTime, loadEndTime.Sub(loadStartTime))
+	g.logger.loaded(key, loadEndTime.Sub(loadStartTime))
+	trace.loadDone(key, nil)
+	g.Hooks.loadEnd(key, result.Value, nil, loadEndTime.Sub(loadStartTime))
+	return result, nil
 }
 
-func (g *genericCache) concurrentEvict(key interface{}, reason RemovalReason) {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
-	g.evict(key, reason)
+func (g *genericCache) Refresh(ctx context.Context, key interface{}) error {
+	if !g.lifecycle.begin() {
+		return ErrClosed
+	}
+	defer g.lifecycle.end()
+	if g.loader == nil {
+		return ErrKeyNotFound
+	}
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+	result, err := g.loadResult(ctx, key, ContextTrace(ctx))
+	if err != nil || result.NoCache {
+		return err
+	}
+	if _, exists := g.lookup(key); exists {
+		g.evict(key, RemovalReasonReplaced)
+	}
+	g.internalPut(key, result)
+	return nil
+}
+
+// callLoader calls the loading function, converting panics into a *LoadPanicError.
+func (g *genericCache) callLoader(ctx context.Context, key interface{}) (result LoadResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.recordPanic()
+			err = &LoadPanicError{Key: key, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return g.loader(ctx, key)
 }
 
 func (g *genericCache) runBackgroundEvict() {
 	ticker := g.Clock.Ticker(g.BackgroundEvictFrequency)
 	defer ticker.Stop()
-	defer g.backgroundWg.Done()
+	defer g.lifecycle.end()
 	for {
 		select {
 		case <-g.done:
@@ -338,27 +890,36 @@ func (g *genericCache) runBackgroundEvict() {
 // backgroundEvict performs a scan of the cache in search for expired entries
 // and evicts them
 func (g *genericCache) backgroundEvict() {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
-	for key := range g.data {
-		entry := g.data[key]
-		if g.isExpired(entry) {
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+	now := g.Clock.Now()
+	var evicted int
+	g.rangeData(func(entry *cacheEntry) bool {
+		if g.isExpired(entry, now) {
 			// TODO: There's a possibility that we want to evict
 			// in a go routine so we can get through
 			// all expired entries as fast as possible without
 			// having to sequentially wait for removal listeners.
 			g.evict(entry.key, RemovalReasonExpired)
+			evicted++
 		}
-	}
+		return true
+	})
+	g.logger.swept(evicted, g.Clock.Since(now))
 }
 
+// evict removes an entry and notifies removal listeners.
+// It does not handle any synchronization, leaving that to the caller.
 func (g *genericCache) evict(key interface{}, reason RemovalReason) {
-	val, exists := g.data[key]
+	val, exists := g.delete(key)
 	if !exists {
 		return
 	}
-	g.stats.Eviction()
-	delete(g.data, key)
+	g.recordEviction(reason, val.weight)
+	g.logger.evicted(key, reason)
+	if reason == RemovalReasonExpired {
+		g.Hooks.expire(key, val.value)
+	}
 
 	if len(g.RemovalListeners) == 0 {
 		return
@@ -378,30 +939,74 @@ func (g *genericCache) evict(key interface{}, reason RemovalReason) {
 		listener := g.RemovalListeners[i]
 		go func() {
 			defer listenerWg.Done()
+			defer g.recoverListener(notification)
 			listener(notification)
 		}()
 	}
 	listenerWg.Wait()
 }
 
+// recoverListener recovers from a panicking removal listener,
+// reporting it to the error handler.
+func (g *genericCache) recoverListener(notification RemovalNotification) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	g.recordPanic()
+	if g.ErrorHandler != nil {
+		g.ErrorHandler(&ListenerPanicError{Notification: notification, Value: r, Stack: debug.Stack()})
+	}
+}
+
+// evictAny evicts an arbitrary entry due to the cache size.
+// It does not handle any synchronization, leaving that to the caller.
+func (g *genericCache) evictAny() {
+	// If eviction is needed it currently removes a random entry,
+	// since maps do not have a deterministic order.
+	// TODO: Apply smarter eviction policies if available
+	g.rangeData(func(entry *cacheEntry) bool {
+		g.evict(entry.key, RemovalReasonSize)
+		return false
+	})
+}
+
 // internalPut actually saves the values into the internal structures.
 // It does not handle any synchronization, leaving that to the caller.
-func (g *genericCache) internalPut(key interface{}, value interface{}) {
-	if g.MaxSize > 0 && int32(len(g.data)) >= g.MaxSize {
-		// If eviction is needed it currently removes a random entry,
-		// since maps do not have a deterministic order.
-		// TODO: Apply smarter eviction policies if available
-		for toEvict := range g.data {
-			g.evict(toEvict, RemovalReasonSize)
-			break
-		}
+//
+// The result weight must be positive.
+func (g *genericCache) internalPut(key interface{}, result LoadResult) {
+	for g.exceedsCapacity(result.Weight) {
+		g.evictAny()
 	}
-	g.data[key] = &cacheEntry{
+	now := g.Clock.Now()
+	entry := &cacheEntry{
 		key:       key,
-		value:     value,
-		lastRead:  g.Clock.Now(),
-		lastWrite: g.Clock.Now(),
+		value:     result.Value,
+		lastWrite: now,
+		ttl:       result.TTL,
+		weight:    result.Weight,
+		tags:      result.Tags,
+	}
+	entry.lastRead.Store(now.UnixNano())
+	if g.hasTTLJitter() {
+		entry.jitter = g.JitterSource()
 	}
+	g.data.Store(key, entry)
+	g.size++
+	g.totalWeight += entry.weight
+}
+
+// exceedsCapacity checks if adding an entry with the given weight
+// would go over the size or weight limits of the cache.
+func (g *genericCache) exceedsCapacity(weight int64) bool {
+	if g.size == 0 {
+		return false
+	}
+	if maxSize := g.maxSize.Load(); maxSize > 0 && g.size >= maxSize {
+		return true
+	}
+	return g.MaxWeight > 0 && g.totalWeight+weight > g.MaxWeight
 }
 
 // preWriteCleanup does a pass through all entries to assess if any are expired
@@ -412,46 +1017,107 @@ func (g *genericCache) preWriteCleanup() {
 	if g.BackgroundEvictFrequency > 0 {
 		return
 	}
-	for key := range g.data {
-		if g.isExpired(g.data[key]) {
-			g.evict(key, RemovalReasonExpired)
+	now := g.Clock.Now()
+	g.rangeData(func(entry *cacheEntry) bool {
+		if g.isExpired(entry, now) {
+			g.evict(entry.key, RemovalReasonExpired)
 		}
-	}
+		return true
+	})
 }
 
 func (g *genericCache) Put(key interface{}, value interface{}) {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
+	if !g.lifecycle.begin() {
+		return
+	}
+	defer g.lifecycle.end()
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
 	g.preWriteCleanup()
-	if _, exists := g.data[key]; exists {
+	if _, exists := g.lookup(key); exists {
 		g.evict(key, RemovalReasonReplaced)
 	}
-	g.internalPut(key, value)
+	g.internalPut(key, LoadResult{Value: value, Weight: 1})
+	g.Hooks.put(key, value)
 }
 
 func (g *genericCache) Invalidate(key interface{}, keys ...interface{}) {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
-	delete(g.data, key)
+	if !g.lifecycle.begin() {
+		return
+	}
+	defer g.lifecycle.end()
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+	g.delete(key)
 	for _, k := range keys {
-		delete(g.data, k)
+		g.delete(k)
 	}
 }
 
 func (g *genericCache) InvalidateAll() {
-	g.dataLock.Lock()
-	defer g.dataLock.Unlock()
-	for key := range g.data {
-		delete(g.data, key)
+	if !g.lifecycle.begin() {
+		return
 	}
+	defer g.lifecycle.end()
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+	g.rangeData(func(entry *cacheEntry) bool {
+		g.delete(entry.key)
+		return true
+	})
+}
+
+// delete removes an entry without notifying removal listeners, returning it if it existed.
+// It does not handle any synchronization, leaving that to the caller.
+func (g *genericCache) delete(key interface{}) (*cacheEntry, bool) {
+	val, exists := g.data.LoadAndDelete(key)
+	if !exists {
+		return nil, false
+	}
+	entry := val.(*cacheEntry)
+	g.size--
+	g.totalWeight -= entry.weight
+	return entry, true
 }
 
 func (g *genericCache) Close() {
-	close(g.done)
-	// Ensure that we wait for all background tasks to complete.
-	g.backgroundWg.Wait()
+	_ = g.Shutdown(context.Background())
 }
 
+func (g *genericCache) Shutdown(ctx context.Context) error {
+	closing := g.lifecycle.close()
+	if closing {
+		close(g.done)
+	}
+	// Ensure that we wait for all in-flight operations and background tasks to complete.
+	err := g.lifecycle.wait(ctx)
+	if closing || err != nil {
+		g.logger.shutDown(err)
+	}
+	return err
+}
+
+// emptyStats backs the stats of caches which do not record them. It is never
+// written to, so it can be shared instead of allocating a sizeable collector.
+var emptyStats = &stats.InternalStats{}
+
 func (g *genericCache) Stats() Stats {
-	return g.stats
+	if g.stats == nil {
+		return liveStats{emptyStats, g.Clock}
+	}
+	return liveStats{g.stats, g.Clock}
+}
+
+func (g *genericCache) ResetStats() {
+	if g.stats != nil {
+		g.stats.Reset()
+	}
+}
+
+func (g *genericCache) StatsSnapshot() StatsSnapshot {
+	return newStatsSnapshot(g.Stats())
+}
+
+func (g *genericCache) Policy() Policy {
+	return genericPolicy{cache: g}
 }
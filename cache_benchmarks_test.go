@@ -75,3 +75,51 @@ func BenchmarkPutAtMaxSize(b *testing.B) {
 		cache.Put(i, 1)
 	}
 }
+
+// benchmarkKeyCount is the number of distinct keys used by parallel benchmarks
+const benchmarkKeyCount = 1024
+
+func BenchmarkGetHitParallel(b *testing.B) {
+	matrixBenchmark(b,
+		loadingcache.CacheOptions{},
+		func(b *testing.B, cache loadingcache.Cache) {
+			for i := 0; i < benchmarkKeyCount; i++ {
+				cache.Put(i, "a")
+			}
+		},
+		func(b *testing.B, cache loadingcache.Cache) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					_, err := cache.Get(i % benchmarkKeyCount)
+					if err != nil {
+						panic(err)
+					}
+				}
+			})
+		})
+}
+
+func BenchmarkGetHitParallelWithWrites(b *testing.B) {
+	matrixBenchmark(b,
+		loadingcache.CacheOptions{BackgroundEvictFrequency: time.Minute},
+		func(b *testing.B, cache loadingcache.Cache) {
+			for i := 0; i < benchmarkKeyCount; i++ {
+				cache.Put(i, "a")
+			}
+		},
+		func(b *testing.B, cache loadingcache.Cache) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					// One in every 100 operations replaces a value
+					if i%100 == 0 {
+						cache.Put(i%benchmarkKeyCount, "b")
+						continue
+					}
+					_, err := cache.Get(i % benchmarkKeyCount)
+					if err != nil {
+						panic(err)
+					}
+				}
+			})
+		})
+}
@@ -1,11 +1,11 @@
 package loadingcache_test
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Hartimer/loadingcache"
-	"github.com/pkg/errors"
 )
 
 func ExampleCache_simpleUsage() {
@@ -2,12 +2,13 @@ package loadingcache_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/Hartimer/loadingcache"
-	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,7 +17,7 @@ func TestBasicMethods(t *testing.T) {
 		// Getting a key that does not exist should error
 		_, err := cache.Get(1)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 
 		// Invalidating a key that doesn't exist
 		cache.Invalidate(1)
@@ -41,7 +42,7 @@ func TestBasicMethods(t *testing.T) {
 		cache.Invalidate(1)
 		_, err = cache.Get(1)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 
 		// Invalidate multiple keys at once
 		cache.Put(1, 1)
@@ -49,10 +50,10 @@ func TestBasicMethods(t *testing.T) {
 		cache.Invalidate(1, 2)
 		_, err = cache.Get(1)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 		_, err = cache.Get(2)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 
 		// Invalidate all keys
 		cache.Put(1, 1)
@@ -60,10 +61,10 @@ func TestBasicMethods(t *testing.T) {
 		cache.InvalidateAll()
 		_, err = cache.Get(1)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 		_, err = cache.Get(2)
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 	})
 }
 
@@ -92,10 +93,94 @@ func TestExpireAfterWrite(t *testing.T) {
 			mockClock.Add(1)
 			_, err = cache.Get(1)
 			require.Error(t, err)
-			require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 		})
 }
 
+func TestTTLJitter(t *testing.T) {
+	// Each entry gets its own jitter factor, in the order they are written
+	jitterFactors := []float64{0.5, 0.1}
+	var jitterIndex int
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: time.Minute,
+			TTLJitter:        20 * time.Second,
+			JitterSource: func() float64 {
+				factor := jitterFactors[jitterIndex%len(jitterFactors)]
+				jitterIndex++
+				return factor
+			},
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			jitterIndex = 0
+			// Expires after 50 seconds
+			cache.Put(1, 1)
+			// Expires after 58 seconds
+			cache.Put(2, 2)
+
+			mockClock.Add(50 * time.Second)
+			val, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, val)
+
+			// Moving just past the first threshold should only expire the first entry
+			mockClock.Add(1)
+			_, err = cache.Get(1)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+			val, err = cache.Get(2)
+			require.NoError(t, err)
+			require.Equal(t, 2, val)
+
+			// Moving just past the second threshold
+			mockClock.Add(8 * time.Second)
+			_, err = cache.Get(2)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestTTLJitterFraction(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite:  time.Minute,
+			TTLJitterFraction: 0.5,
+			JitterSource: func() float64 {
+				return 0.5
+			},
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			cache.Put(1, 1)
+
+			// The maximum jitter is 30 seconds, half of which is applied
+			mockClock.Add(45 * time.Second)
+			val, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, val)
+
+			mockClock.Add(1)
+			_, err = cache.Get(1)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestTTLJitterValidation(t *testing.T) {
+	require.Panics(t, func() {
+		loadingcache.New(loadingcache.CacheOptions{TTLJitter: -time.Second})
+	})
+	require.Panics(t, func() {
+		loadingcache.New(loadingcache.CacheOptions{TTLJitterFraction: 1.5})
+	})
+	require.Panics(t, func() {
+		loadingcache.New(loadingcache.CacheOptions{TTLJitter: time.Second, TTLJitterFraction: 0.5})
+	})
+}
+
 func TestExpireAfterRead(t *testing.T) {
 	matrixTest(t, matrixTestOptions{
 		cacheOptions: loadingcache.CacheOptions{
@@ -130,7 +215,7 @@ func TestExpireAfterRead(t *testing.T) {
 			mockClock.Add(time.Minute + 1)
 			_, err = cache.Get(1)
 			require.Error(t, err)
-			require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 		})
 }
 
@@ -172,6 +257,103 @@ func TestLoadFunc(t *testing.T) {
 		})
 }
 
+func TestLoadWithResult(t *testing.T) {
+	const (
+		shortLivedKey = iota
+		uncachedKey
+		longLivedKey
+	)
+	var loadCount int
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: time.Hour,
+			LoadWithResult: func(key interface{}) (loadingcache.LoadResult, error) {
+				loadCount++
+				switch key {
+				case shortLivedKey:
+					return loadingcache.LoadResult{Value: key, TTL: time.Minute}, nil
+				case uncachedKey:
+					return loadingcache.LoadResult{Value: key, NoCache: true}, nil
+				default:
+					return loadingcache.LoadResult{Value: key}, nil
+				}
+			},
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			loadCount = 0
+
+			// Values which should not be cached are loaded every time
+			for i := 1; i <= 2; i++ {
+				val, err := cache.Get(uncachedKey)
+				require.NoError(t, err)
+				require.Equal(t, uncachedKey, val)
+				require.Equal(t, i, loadCount)
+			}
+
+			// Entries with their own TTL expire independently of the cache TTL
+			val, err := cache.Get(shortLivedKey)
+			require.NoError(t, err)
+			require.Equal(t, shortLivedKey, val)
+			val, err = cache.Get(longLivedKey)
+			require.NoError(t, err)
+			require.Equal(t, longLivedKey, val)
+			require.Equal(t, 4, loadCount)
+
+			mockClock.Add(time.Minute + 1)
+			_, err = cache.Get(shortLivedKey)
+			require.NoError(t, err)
+			_, err = cache.Get(longLivedKey)
+			require.NoError(t, err)
+			require.Equal(t, 5, loadCount)
+		})
+}
+
+func TestMaxWeight(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		MaxWeight: 10,
+		LoadWithResult: func(key interface{}) (loadingcache.LoadResult, error) {
+			return loadingcache.LoadResult{Value: key, Weight: int64(key.(int))}, nil
+		},
+	})
+
+	_, err := cache.Get(6)
+	require.NoError(t, err)
+
+	// Adding an entry with weight 5 goes over the limit,
+	// so the previous entry must be evicted
+	_, err = cache.Get(5)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cache.Stats().EvictionCount())
+	require.Equal(t, int64(6), cache.Stats().EvictionWeightByReason(loadingcache.RemovalReasonSize))
+	require.Equal(t, int64(5), cache.Weight())
+
+	// Entries with weight 1 fit alongside
+	cache.Put(1, 1)
+	require.Equal(t, int64(1), cache.Stats().EvictionCount())
+
+	// Entries which can never fit fail to load
+	_, err = cache.Get(11)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, loadingcache.ErrCapacityExceeded))
+	require.Equal(t, int64(1), cache.Stats().LoadErrorCount())
+}
+
+func TestLoadFuncValidation(t *testing.T) {
+	require.Panics(t, func() {
+		loadingcache.New(loadingcache.CacheOptions{
+			Load: (&testLoadFunc{}).LoadFunc,
+			LoadWithResult: func(key interface{}) (loadingcache.LoadResult, error) {
+				return loadingcache.LoadResult{}, nil
+			},
+		})
+	})
+	require.Panics(t, func() {
+		loadingcache.New(loadingcache.CacheOptions{MaxWeight: -1})
+	})
+}
+
 func TestMaxSize(t *testing.T) {
 	// TODO MaxSize is currently not properly enforced in a sharded environment
 	caches := []loadingcache.Cache{
@@ -192,7 +374,7 @@ func TestMaxSize(t *testing.T) {
 
 		_, err := cache.Get("a")
 		require.Error(t, err)
-		require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
 
 		val, err := cache.Get("b")
 		require.NoError(t, err)
@@ -306,6 +488,176 @@ func TestBackgroudEvict(t *testing.T) {
 
 			_, err = cache.Get(1)
 			require.Error(t, err)
-			require.Equal(t, loadingcache.ErrKeyNotFound, errors.Cause(err))
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterRead:          time.Minute,
+			BackgroundEvictFrequency: 10 * time.Second,
+			MaxSize:                  50,
+			Load: func(key interface{}) (interface{}, error) {
+				return key.(int) * 10, nil
+			},
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			const workers, iterations, keys = 4, 300, 100
+			errs := make(chan error, workers)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < iterations; i++ {
+						key := (w*iterations + i) % keys
+						switch i % 3 {
+						case 0:
+							val, err := cache.Get(key)
+							if err != nil {
+								errs <- err
+								return
+							}
+							if val != key*10 {
+								errs <- fmt.Errorf("unexpected value %v for key %d", val, key)
+								return
+							}
+						case 1:
+							cache.Put(key, key*10)
+						case 2:
+							cache.Invalidate(key)
+						}
+					}
+				}(w)
+			}
+
+			// Expire entries and trigger the background eviction while the workers run
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 10; i++ {
+					mockClock.Add(10 * time.Second)
+				}
+			}()
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				require.NoError(t, err)
+			}
+			require.LessOrEqual(t, cache.Len(), 50)
+		})
+}
+
+func TestClose(t *testing.T) {
+	matrixTest(t, matrixTestOptions{}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		cache.Put(1, 1)
+
+		// Closing multiple times is safe
+		cache.Close()
+		cache.Close()
+		require.NoError(t, cache.Shutdown(context.Background()))
+
+		_, err := cache.Get(1)
+		require.True(t, errors.Is(err, loadingcache.ErrClosed))
+
+		// Other operations are noops
+		cache.Put(2, 2)
+		cache.Invalidate(1)
+		cache.InvalidateAll()
+	})
+}
+
+func TestShutdownWaitsForLoads(t *testing.T) {
+	loadStarted := make(chan struct{})
+	releaseLoad := make(chan struct{})
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Load: func(key interface{}) (interface{}, error) {
+				loadStarted <- struct{}{}
+				<-releaseLoad
+				return key, nil
+			},
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			type getResult struct {
+				val interface{}
+				err error
+			}
+			results := make(chan getResult, 1)
+			go func() {
+				val, err := cache.Get(1)
+				results <- getResult{val: val, err: err}
+			}()
+			<-loadStarted
+
+			// The load is still in-flight, so shutting down must give up once the context ends
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			require.True(t, errors.Is(cache.Shutdown(ctx), context.DeadlineExceeded))
+
+			// New operations are rejected, even though the cache did not drain yet
+			_, err := cache.Get(2)
+			require.True(t, errors.Is(err, loadingcache.ErrClosed))
+
+			releaseLoad <- struct{}{}
+			require.NoError(t, cache.Shutdown(context.Background()))
+
+			// The in-flight load completed normally
+			result := <-results
+			require.NoError(t, result.err)
+			require.Equal(t, 1, result.val)
 		})
 }
+
+func TestRefresh(t *testing.T) {
+	loadFunc := &testLoadFunc{}
+	removalListener := &testRemovalListener{}
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Load:             loadFunc.LoadFunc,
+			RemovalListeners: []loadingcache.RemovalListener{removalListener.Listener},
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		defer func() {
+			loadFunc.fail = false
+		}()
+
+		// Refreshing a missing key loads it
+		require.NoError(t, cache.Refresh(context.Background(), 1))
+		val, err := cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, "1", val)
+
+		// Refreshing an existing key replaces it
+		cache.Put(1, "a")
+		require.NoError(t, cache.Refresh(context.Background(), 1))
+		require.Equal(t, loadingcache.RemovalReasonReplaced, removalListener.lastRemovalNotification.Reason)
+		require.Equal(t, "a", removalListener.lastRemovalNotification.Value)
+		val, err = cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, "1", val)
+
+		// A failed refresh keeps the cached value
+		cache.Put(1, "a")
+		loadFunc.fail = true
+		err = cache.Refresh(context.Background(), 1)
+		require.True(t, errors.Is(err, errTestLoadFailed))
+		val, err = cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, "a", val)
+		require.Equal(t, int64(1), cache.Stats().LoadErrorCount())
+	})
+}
+
+func TestRefreshWithoutLoader(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{})
+	cache.Put(1, "a")
+	require.True(t, errors.Is(cache.Refresh(context.Background(), 1), loadingcache.ErrKeyNotFound))
+
+	cache.Close()
+	require.True(t, errors.Is(cache.Refresh(context.Background(), 1), loadingcache.ErrClosed))
+}
@@ -2,13 +2,13 @@ package loadingcache_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"testing"
 
 	"github.com/Hartimer/loadingcache"
 	"github.com/benbjohnson/clock"
-	"github.com/pkg/errors"
 	"go.uber.org/goleak"
 )
 
@@ -20,6 +20,9 @@ func (t *testRemovalListener) Listener(notification loadingcache.RemovalNotifica
 	t.lastRemovalNotification = notification
 }
 
+// errTestLoadFailed is returned by testLoadFunc when it is configured to fail
+var errTestLoadFailed = errors.New("failing on request")
+
 // testLoadFunc provides a configurable loading function that may fail
 type testLoadFunc struct {
 	fail bool
@@ -27,7 +30,7 @@ type testLoadFunc struct {
 
 func (t *testLoadFunc) LoadFunc(key interface{}) (interface{}, error) {
 	if t.fail {
-		return nil, errors.New("failing on request")
+		return nil, errTestLoadFailed
 	}
 	return fmt.Sprint(key), nil
 }
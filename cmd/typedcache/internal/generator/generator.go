@@ -3,13 +3,14 @@ package generator
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"html/template"
 	"io/ioutil"
 	"path/filepath"
 	"regexp"
 	"strings"
 
-	"github.com/pkg/errors"
 	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/imports"
 )
@@ -73,7 +74,7 @@ func parseType(str string) (*goType, error) {
 	if t.ImportPath != "" {
 		p, err := packages.Load(&packages.Config{Mode: packages.NeedName}, t.ImportPath)
 		if err != nil {
-			return nil, errors.Wrap(err, "")
+			return nil, err
 		}
 		if len(p) != 1 {
 			return nil, errors.New("not found")
@@ -91,7 +92,7 @@ func getPackage(dir string) (*packages.Package, error) {
 	}, ".")
 
 	if err != nil {
-		return nil, errors.Wrap(err, "")
+		return nil, err
 	}
 
 	if len(p) != 1 {
@@ -108,16 +109,16 @@ func Generate(wd string, name string, keyType string, valueType string) error {
 	var err error
 	tmplValues.KeyType, err = parseType(keyType)
 	if err != nil {
-		return errors.Wrap(err, "faile to parse key type")
+		return fmt.Errorf("faile to parse key type: %w", err)
 	}
 	tmplValues.ValueType, err = parseType(valueType)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse value type")
+		return fmt.Errorf("failed to parse value type: %w", err)
 	}
 
 	genPkg, err := getPackage(wd)
 	if err != nil {
-		return errors.Wrap(err, "failed to get package out of current working directory")
+		return fmt.Errorf("failed to get package out of current working directory: %w", err)
 	}
 	tmplValues.Package = genPkg.Name
 
@@ -137,16 +138,16 @@ func Generate(wd string, name string, keyType string, valueType string) error {
 	var buf bytes.Buffer
 	tmpl := template.Must(template.New("typedCacheTemplate").Parse(typedCacheTemplate))
 	if err := tmpl.Execute(&buf, tmplValues); err != nil {
-		return errors.Wrap(err, "failed to execute template")
+		return fmt.Errorf("failed to execute template: %w", err)
 	}
 	src, err := imports.Process(filepath, buf.Bytes(), nil)
 	if err != nil {
-		return errors.Wrap(err, "failed to apply go imports")
+		return fmt.Errorf("failed to apply go imports: %w", err)
 	}
 	// Disabling gosec specifically here since we do want the generated code to be readable
 	//nolint:gosec
 	if err := ioutil.WriteFile(filepath, src, 0644); err != nil {
-		return errors.Wrap(err, "failed to write file")
+		return fmt.Errorf("failed to write file: %w", err)
 	}
 	return nil
 }
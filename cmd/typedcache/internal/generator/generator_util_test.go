@@ -27,6 +27,8 @@ type {{.Name}}Options struct {
 	RemovalListeners []RemovalListener
 	ShardCount       int
 	HashCodeFunc     func(key string) int
+	Hooks            loadingcache.Hooks
+	Middlewares      []loadingcache.Middleware
 }
 
 func (c {{.Name}}Options) expiresAfterRead() bool {
@@ -59,6 +61,8 @@ func New{{.Name}}(options {{.Name}}Options) {{.Name}} {
 		MaxSize:          options.MaxSize,
 		RemovalListeners: make([]loadingcache.RemovalListener, len(options.RemovalListeners)),
 		ShardCount:       options.ShardCount,
+		Hooks:            options.Hooks,
+		Middlewares:      options.Middlewares,
 	}
 
 	if options.Load != nil {
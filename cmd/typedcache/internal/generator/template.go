@@ -21,6 +21,8 @@ type {{.Name}}Options struct {
 	RemovalListeners []RemovalListener
 	ShardCount       int
 	HashCodeFunc     func(key {{.KeyType}}) int
+	Hooks            loadingcache.Hooks
+	Middlewares      []loadingcache.Middleware
 }
 
 func (c {{.Name}}Options) expiresAfterRead() bool {
@@ -53,6 +55,8 @@ func New{{.Name}}(options {{.Name}}Options) {{.Name}} {
 		MaxSize:          options.MaxSize,
 		RemovalListeners: make([]loadingcache.RemovalListener, len(options.RemovalListeners)),
 		ShardCount:       options.ShardCount,
+		Hooks:            options.Hooks,
+		Middlewares:      options.Middlewares,
 	}
 
 	if options.Load != nil {
@@ -12,7 +12,6 @@ import (
 
 	"github.com/Hartimer/loadingcache"
 	"github.com/benbjohnson/clock"
-	"github.com/pkg/errors"
 )
 
 type TypedCache interface {
@@ -31,6 +30,8 @@ type CacheOptions struct {
 	RemovalListeners []RemovalListener
 	ShardCount       int
 	HashCodeFunc     func(key string) int
+	Hooks            loadingcache.Hooks
+	Middlewares      []loadingcache.Middleware
 }
 
 func (c CacheOptions) expiresAfterRead() bool {
@@ -63,6 +64,8 @@ func NewTypedCache(options CacheOptions) TypedCache {
 		MaxSize:          options.MaxSize,
 		RemovalListeners: make([]loadingcache.RemovalListener, len(options.RemovalListeners)),
 		ShardCount:       options.ShardCount,
+		Hooks:            options.Hooks,
+		Middlewares:      options.Middlewares,
 	}
 
 	if options.Load != nil {
@@ -97,7 +100,7 @@ func NewTypedCache(options CacheOptions) TypedCache {
 func (i *internalImplementation) Get(key string) (int64, error) {
 	val, err := i.genericCache.Get(key)
 	if err != nil {
-		return 0, errors.Wrap(err, "")
+		return 0, err
 	}
 	typedVal, ok := val.(int64)
 	if !ok {
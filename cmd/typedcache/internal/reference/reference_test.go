@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Hartimer/loadingcache"
 	"github.com/Hartimer/loadingcache/cmd/typedcache/internal/reference"
 )
 
@@ -41,3 +42,25 @@ func ExampleTypedCache() {
 	// Entry removed due to SIZE
 	// 3
 }
+
+func ExampleTypedCache_middlewares() {
+	cache := reference.NewTypedCache(reference.CacheOptions{
+		Middlewares: []loadingcache.Middleware{
+			func(next loadingcache.Operations) loadingcache.Operations {
+				put := next.Put
+				next.Put = func(key interface{}, value interface{}) {
+					fmt.Printf("Putting key %v\n", key)
+					put(key, value)
+				}
+				return next
+			},
+		},
+	})
+
+	cache.Put("a", 1)
+	val, _ := cache.Get("a")
+	fmt.Printf("%v\n", val)
+
+	// Output: Putting key a
+	// 1
+}
@@ -0,0 +1,91 @@
+package loadingcache
+
+import "time"
+
+// Entry describes a cached value and its metadata, which is useful e.g.
+// to debug why a value is stale.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+
+	// WriteTime is when the entry was written
+	WriteTime time.Time
+
+	// AccessTime is when the entry was last read, or written
+	// if it was never read
+	AccessTime time.Time
+
+	// ExpiresAt is the deadline after which the entry expires,
+	// or the zero time if it never expires.
+	ExpiresAt time.Time
+
+	// RemainingTTL is the time left until ExpiresAt, or 0 if the
+	// entry never expires.
+	RemainingTTL time.Duration
+
+	// AccessCount is the number of times the entry was read
+	AccessCount int64
+
+	// Weight is the weight of the entry. See CacheOptions.MaxWeight.
+	Weight int64
+
+	// Tags are the tags the entry was loaded with. See LoadResult.
+	Tags []string
+}
+
+func (g *genericCache) GetEntry(key interface{}) (Entry, error) {
+	if !g.lifecycle.begin() {
+		return Entry{}, ErrClosed
+	}
+	defer g.lifecycle.end()
+
+	entry, exists := g.lookup(key)
+	if !exists || g.isExpired(entry, g.Clock.Now()) {
+		return Entry{}, ErrKeyNotFound
+	}
+	return g.describe(entry), nil
+}
+
+func (g *genericCache) RangeEntries(f func(Entry) bool) {
+	if !g.lifecycle.begin() {
+		return
+	}
+	defer g.lifecycle.end()
+
+	// Entries are visited without the write lock, so iterating never
+	// stalls behind loads. Entries are never mutated once stored, so each
+	// one is consistent, even if the set of entries is not.
+	now := g.Clock.Now()
+	g.rangeData(func(entry *cacheEntry) bool {
+		if g.isExpired(entry, now) {
+			return true
+		}
+		return f(g.describe(entry))
+	})
+}
+
+// describe converts an internal entry into its public representation.
+func (g *genericCache) describe(entry *cacheEntry) Entry {
+	described := Entry{
+		Key:         entry.key,
+		Value:       entry.value,
+		WriteTime:   entry.lastWrite,
+		AccessTime:  entry.lastReadTime(),
+		AccessCount: entry.accessCount.Load(),
+		Weight:      entry.weight,
+		Tags:        entry.tags,
+	}
+	if ttl, expires := g.writeTTL(entry); expires {
+		described.ExpiresAt = entry.lastWrite.Add(ttl)
+	}
+	if readTTL := time.Duration(g.expireAfterRead.Load()); readTTL > 0 {
+		readDeadline := described.AccessTime.Add(readTTL)
+		if described.ExpiresAt.IsZero() || readDeadline.Before(described.ExpiresAt) {
+			described.ExpiresAt = readDeadline
+		}
+	}
+	if !described.ExpiresAt.IsZero() {
+		described.RemainingTTL = described.ExpiresAt.Sub(g.Clock.Now())
+	}
+	return described
+}
@@ -0,0 +1,114 @@
+package loadingcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEntry(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: 10 * time.Minute,
+			ExpireAfterRead:  time.Minute,
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			_, err := cache.GetEntry(1)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+
+			writeTime := mockClock.Now()
+			cache.Put(1, "a")
+			mockClock.Add(30 * time.Second)
+			_, err = cache.Get(1)
+			require.NoError(t, err)
+			readTime := mockClock.Now()
+			mockClock.Add(15 * time.Second)
+
+			entry, err := cache.GetEntry(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, entry.Key)
+			require.Equal(t, "a", entry.Value)
+			require.Equal(t, writeTime, entry.WriteTime)
+			require.Equal(t, readTime, entry.AccessTime)
+			require.Equal(t, int64(1), entry.AccessCount)
+			require.Equal(t, int64(1), entry.Weight)
+			// The read expiry comes first
+			require.Equal(t, readTime.Add(time.Minute), entry.ExpiresAt)
+			require.Equal(t, 45*time.Second, entry.RemainingTTL)
+
+			// Inspecting the entry does not count as an access
+			entry, err = cache.GetEntry(1)
+			require.NoError(t, err)
+			require.Equal(t, readTime, entry.AccessTime)
+			require.Equal(t, int64(1), entry.AccessCount)
+			require.Equal(t, int64(1), cache.Stats().RequestCount())
+
+			// Expired entries are not found
+			mockClock.Add(time.Minute)
+			_, err = cache.GetEntry(1)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestGetEntryWithLoadResult(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			LoadWithResult: func(key interface{}) (loadingcache.LoadResult, error) {
+				return loadingcache.LoadResult{Value: key, TTL: time.Minute, Weight: 5, Tags: []string{"tag"}}, nil
+			},
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			_, err := cache.Get(1)
+			require.NoError(t, err)
+
+			entry, err := cache.GetEntry(1)
+			require.NoError(t, err)
+			require.Equal(t, int64(5), entry.Weight)
+			require.Equal(t, []string{"tag"}, entry.Tags)
+			require.Equal(t, mockClock.Now().Add(time.Minute), entry.ExpiresAt)
+			require.Equal(t, time.Minute, entry.RemainingTTL)
+		})
+}
+
+func TestRangeEntries(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: time.Minute,
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			cache.Put(1, 1)
+			mockClock.Add(30 * time.Second)
+			cache.Put(2, 2)
+			cache.Put(3, 3)
+			mockClock.Add(31 * time.Second)
+
+			// The first entry expired
+			entries := map[interface{}]loadingcache.Entry{}
+			cache.RangeEntries(func(entry loadingcache.Entry) bool {
+				entries[entry.Key] = entry
+				return true
+			})
+			require.Len(t, entries, 2)
+			require.Equal(t, 2, entries[2].Value)
+			require.Equal(t, 29*time.Second, entries[2].RemainingTTL)
+			require.Equal(t, 3, entries[3].Value)
+
+			// Stopping the iteration early
+			var count int
+			cache.RangeEntries(func(entry loadingcache.Entry) bool {
+				count++
+				return false
+			})
+			require.Equal(t, 1, count)
+		})
+}
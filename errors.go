@@ -0,0 +1,84 @@
+package loadingcache
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrKeyNotFound represents an error indicating that the key was not found
+	ErrKeyNotFound = errors.New("Key not found")
+
+	// ErrClosed is returned by operations on a cache which was closed
+	ErrClosed = errors.New("cache is closed")
+
+	// ErrCapacityExceeded is returned when an entry can never fit in the cache,
+	// e.g. when its weight alone exceeds MaxWeight.
+	ErrCapacityExceeded = errors.New("capacity exceeded")
+
+	// ErrInvalidOptions is returned when the cache is configured with invalid options
+	ErrInvalidOptions = errors.New("invalid cache options")
+
+	// ErrAlreadyRegistered is returned when registering a cache under a name which is taken
+	ErrAlreadyRegistered = errors.New("cache already registered")
+)
+
+func invalidOptions(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOptions, reason)
+}
+
+// LoadError is returned when the loading function fails to load a value.
+//
+// The cause of the failure can be inspected with errors.Is and errors.As.
+// For instance, if the loading function panicked, the cause is a *LoadPanicError.
+type LoadError struct {
+	// Key is the key being loaded
+	Key interface{}
+
+	// Attempts is the number of times the loading function was called
+	Attempts int
+
+	// Err is the underlying cause of the failure
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("failed to load key %v: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying cause of the failure
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadPanicError is returned when the loading function panics.
+type LoadPanicError struct {
+	// Key is the key being loaded
+	Key interface{}
+
+	// Value is the value the loading function panicked with
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine where the panic happened
+	Stack []byte
+}
+
+func (e *LoadPanicError) Error() string {
+	return fmt.Sprintf("panic while loading key %v: %v", e.Key, e.Value)
+}
+
+// ListenerPanicError is reported to the error handler when a removal listener panics.
+type ListenerPanicError struct {
+	// Notification is the notification passed to the removal listener
+	Notification RemovalNotification
+
+	// Value is the value the removal listener panicked with
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine where the panic happened
+	Stack []byte
+}
+
+func (e *ListenerPanicError) Error() string {
+	return fmt.Sprintf("panic in removal listener for key %v: %v", e.Notification.Key, e.Value)
+}
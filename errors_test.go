@@ -0,0 +1,98 @@
+package loadingcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadError(t *testing.T) {
+	loadFunc := &testLoadFunc{fail: true}
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Load: loadFunc.LoadFunc,
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			_, err := cache.Get(1)
+			require.Error(t, err)
+
+			var loadErr *loadingcache.LoadError
+			require.True(t, errors.As(err, &loadErr))
+			require.Equal(t, 1, loadErr.Key)
+			require.Equal(t, 1, loadErr.Attempts)
+			require.True(t, errors.Is(err, errTestLoadFailed))
+			require.False(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestLoadPanic(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Load: func(key interface{}) (interface{}, error) {
+				panic("boom")
+			},
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			_, err := cache.Get(1)
+			require.Error(t, err)
+
+			var loadErr *loadingcache.LoadError
+			require.True(t, errors.As(err, &loadErr))
+			require.Equal(t, 1, loadErr.Key)
+
+			var panicErr *loadingcache.LoadPanicError
+			require.True(t, errors.As(err, &panicErr))
+			require.Equal(t, 1, panicErr.Key)
+			require.Equal(t, "boom", panicErr.Value)
+			require.NotEmpty(t, panicErr.Stack)
+
+			require.Equal(t, int64(1), cache.Stats().PanicCount())
+			require.Equal(t, int64(1), cache.Stats().LoadErrorCount())
+
+			// The cache must still be usable
+			cache.Put(1, 1)
+			val, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, val)
+		})
+}
+
+func TestRemovalListenerPanic(t *testing.T) {
+	var reportedErrors []error
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			RemovalListeners: []loadingcache.RemovalListener{
+				func(notification loadingcache.RemovalNotification) {
+					panic("boom")
+				},
+			},
+			ErrorHandler: func(err error) {
+				reportedErrors = append(reportedErrors, err)
+			},
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			reportedErrors = nil
+
+			// Replacing a value notifies the listener
+			cache.Put(1, 1)
+			cache.Put(1, 2)
+
+			require.Len(t, reportedErrors, 1)
+			var panicErr *loadingcache.ListenerPanicError
+			require.True(t, errors.As(reportedErrors[0], &panicErr))
+			require.Equal(t, 1, panicErr.Notification.Key)
+			require.Equal(t, loadingcache.RemovalReasonReplaced, panicErr.Notification.Reason)
+			require.Equal(t, "boom", panicErr.Value)
+			require.Equal(t, int64(1), cache.Stats().PanicCount())
+
+			val, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, 2, val)
+		})
+}
@@ -25,6 +25,8 @@ type CoolCacheOptions struct {
 	RemovalListeners []RemovalListener
 	ShardCount       int
 	HashCodeFunc     func(key Name) int
+	Hooks            loadingcache.Hooks
+	Middlewares      []loadingcache.Middleware
 }
 
 func (c CoolCacheOptions) expiresAfterRead() bool {
@@ -57,6 +59,8 @@ func NewCoolCache(options CoolCacheOptions) CoolCache {
 		MaxSize:          options.MaxSize,
 		RemovalListeners: make([]loadingcache.RemovalListener, len(options.RemovalListeners)),
 		ShardCount:       options.ShardCount,
+		Hooks:            options.Hooks,
+		Middlewares:      options.Middlewares,
 	}
 
 	if options.Load != nil {
@@ -0,0 +1,16 @@
+package loadingcache
+
+import "expvar"
+
+// PublishExpvar publishes a live view of a cache under a given name, so it is served
+// as JSON by the /debug/vars handler of the expvar package. The view is the CacheInfo
+// returned by DescribeCache, rendered whenever the variable is read.
+//
+// Like expvar.Publish, it panics if the name is already in use.
+func PublishExpvar(name string, cache Cache) expvar.Var {
+	v := expvar.Func(func() interface{} {
+		return DescribeCache(name, cache)
+	})
+	expvar.Publish(name, v)
+	return v
+}
@@ -0,0 +1,61 @@
+package loadingcache_test
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	var published int
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			MaxSize:          2,
+			ExpireAfterWrite: time.Minute,
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		// expvar does not allow unpublishing, so each cache needs its own name
+		published++
+		name := fmt.Sprintf("TestPublishExpvar%d", published)
+		loadingcache.PublishExpvar(name, cache)
+		v := expvar.Get(name)
+		require.NotNil(t, v)
+
+		cache.Put(1, "a")
+		cache.Put(1, "b")
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+
+		var view loadingcache.CacheInfo
+		require.NoError(t, json.Unmarshal([]byte(v.String()), &view))
+		require.Equal(t, name, view.Name)
+		require.Equal(t, 1, view.Size)
+		require.Equal(t, int64(1), view.Weight)
+		require.Equal(t, int32(2), view.Config.MaxSize)
+		require.Equal(t, time.Minute, view.Config.ExpireAfterWrite)
+		require.Equal(t, time.Duration(0), view.Config.ExpireAfterRead)
+		require.Equal(t, int64(1), view.Stats.HitCount())
+		require.Equal(t, map[loadingcache.RemovalReason]loadingcache.EvictionStats{
+			loadingcache.RemovalReasonExplicit: {},
+			loadingcache.RemovalReasonReplaced: {Count: 1, Weight: 1},
+			loadingcache.RemovalReasonExpired:  {},
+			loadingcache.RemovalReasonSize:     {},
+		}, view.Stats.EvictionsByReason)
+
+		// Evictions by reason are only reported within the stats
+		var raw map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal([]byte(v.String()), &raw))
+		require.NotContains(t, raw, "evictions_by_reason")
+
+		// The view is live
+		cache.Put(2, "c")
+		require.NoError(t, json.Unmarshal([]byte(v.String()), &view))
+		require.Equal(t, 2, view.Size)
+	})
+}
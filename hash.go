@@ -0,0 +1,58 @@
+package loadingcache
+
+import "hash/maphash"
+
+// hashSeed is the seed used by DefaultHashCode. It is randomly
+// generated, so hash codes are only stable within a process.
+var hashSeed = maphash.MakeSeed()
+
+// DefaultHashCode produces a hash code for any comparable key.
+// It is used by sharded caches when no HashCodeFunc is configured.
+//
+// Strings and integers are hashed directly, while other types,
+// such as structs, are hashed based on their type and contents,
+// the same way a map would. Like with maps, it panics if the key
+// is not comparable.
+//
+// The returned hash code is never negative.
+func DefaultHashCode(key interface{}) int {
+	var h uint64
+	switch k := key.(type) {
+	case string:
+		h = maphash.String(hashSeed, k)
+	case int:
+		h = mix64(uint64(k))
+	case int8:
+		h = mix64(uint64(k))
+	case int16:
+		h = mix64(uint64(k))
+	case int32:
+		h = mix64(uint64(k))
+	case int64:
+		h = mix64(uint64(k))
+	case uint:
+		h = mix64(uint64(k))
+	case uint8:
+		h = mix64(uint64(k))
+	case uint16:
+		h = mix64(uint64(k))
+	case uint32:
+		h = mix64(uint64(k))
+	case uint64:
+		h = mix64(k)
+	default:
+		h = maphash.Comparable(hashSeed, key)
+	}
+	// Dropping the top bit ensures the result fits a non-negative int
+	return int(uint(h) >> 1)
+}
+
+// mix64 spreads the bits of an integer, using the finalizer of splitmix64.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
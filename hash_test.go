@@ -0,0 +1,73 @@
+package loadingcache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+type compositeKey struct {
+	tenant string
+	id     int
+}
+
+func TestDefaultHashCode(t *testing.T) {
+	keys := []interface{}{
+		"a", "", 0, -1, int8(-1), int64(-42), uint64(1 << 63),
+		compositeKey{tenant: "a", id: 1}, 1.5, true,
+	}
+	for _, key := range keys {
+		hashCode := loadingcache.DefaultHashCode(key)
+		require.GreaterOrEqual(t, hashCode, 0, "key %v", key)
+		require.Equal(t, hashCode, loadingcache.DefaultHashCode(key), "key %v", key)
+	}
+
+	// Equal keys have equal hash codes
+	require.Equal(t,
+		loadingcache.DefaultHashCode(compositeKey{tenant: "a", id: 1}),
+		loadingcache.DefaultHashCode(compositeKey{tenant: "a", id: 1}))
+
+	// Non comparable keys cannot be hashed
+	require.Panics(t, func() {
+		loadingcache.DefaultHashCode([]int{1})
+	})
+}
+
+func TestShardingWithoutHashCodeFunc(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{ShardCount: 16})
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Put(compositeKey{tenant: fmt.Sprint(i), id: i}, i)
+		cache.Put(fmt.Sprint(i), i)
+	}
+	for i := 0; i < 100; i++ {
+		val, err := cache.Get(compositeKey{tenant: fmt.Sprint(i), id: i})
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+		val, err = cache.Get(fmt.Sprint(i))
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+	}
+}
+
+func TestShardingWithNegativeHashCodes(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		ShardCount: 3,
+		HashCodeFunc: func(key interface{}) int {
+			return -key.(int)
+		},
+	})
+	defer cache.Close()
+
+	for i := 0; i < 10; i++ {
+		cache.Put(i, i)
+	}
+	for i := 0; i < 10; i++ {
+		val, err := cache.Get(i)
+		require.NoError(t, err)
+		require.Equal(t, i, val)
+	}
+}
@@ -0,0 +1,70 @@
+package loadingcache
+
+import "time"
+
+// Hooks are functions called on cache events, e.g. to drive logging, auditing
+// or custom metrics. Any particular hook may be nil.
+//
+// Hooks are called synchronously, some of them while holding the cache's write lock,
+// so they must be fast and must not call the cache. Every hook may be called
+// concurrently, whatever the number of shards, since lookups do not take the lock
+// and hooks are shared by all shards, so they must be thread-safe.
+type Hooks struct {
+	// OnHit is called when a lookup finds a cached value
+	OnHit func(key interface{}, value interface{})
+
+	// OnMiss is called when a lookup does not find a cached value,
+	// before loading it if there is a loading function
+	OnMiss func(key interface{})
+
+	// OnLoadStart is called before calling the loading function
+	OnLoadStart func(key interface{})
+
+	// OnLoadEnd is called after calling the loading function, with the loaded value
+	// or the error returned to the caller, and the time spent loading
+	OnLoadEnd func(key interface{}, value interface{}, err error, loadTime time.Duration)
+
+	// OnPut is called when a value is added with Put
+	OnPut func(key interface{}, value interface{})
+
+	// OnExpire is called when an expired entry is evicted
+	OnExpire func(key interface{}, value interface{})
+}
+
+// The following functions call the corresponding hooks, if any.
+
+func (h *Hooks) hit(key interface{}, value interface{}) {
+	if h.OnHit != nil {
+		h.OnHit(key, value)
+	}
+}
+
+func (h *Hooks) miss(key interface{}) {
+	if h.OnMiss != nil {
+		h.OnMiss(key)
+	}
+}
+
+func (h *Hooks) loadStart(key interface{}) {
+	if h.OnLoadStart != nil {
+		h.OnLoadStart(key)
+	}
+}
+
+func (h *Hooks) loadEnd(key interface{}, value interface{}, err error, loadTime time.Duration) {
+	if h.OnLoadEnd != nil {
+		h.OnLoadEnd(key, value, err, loadTime)
+	}
+}
+
+func (h *Hooks) put(key interface{}, value interface{}) {
+	if h.OnPut != nil {
+		h.OnPut(key, value)
+	}
+}
+
+func (h *Hooks) expire(key interface{}, value interface{}) {
+	if h.OnExpire != nil {
+		h.OnExpire(key, value)
+	}
+}
@@ -0,0 +1,97 @@
+package loadingcache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// hookRecorder records the calls to hooks
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) record(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *hookRecorder) hooks() loadingcache.Hooks {
+	return loadingcache.Hooks{
+		OnHit: func(key interface{}, value interface{}) {
+			r.record("hit %v=%v", key, value)
+		},
+		OnMiss: func(key interface{}) {
+			r.record("miss %v", key)
+		},
+		OnLoadStart: func(key interface{}) {
+			r.record("load start %v", key)
+		},
+		OnLoadEnd: func(key interface{}, value interface{}, err error, loadTime time.Duration) {
+			r.record("load end %v=%v %v %v", key, value, err != nil, loadTime)
+		},
+		OnPut: func(key interface{}, value interface{}) {
+			r.record("put %v=%v", key, value)
+		},
+		OnExpire: func(key interface{}, value interface{}) {
+			r.record("expire %v=%v", key, value)
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	mockClock := clock.NewMock()
+	recorder := &hookRecorder{}
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Clock:            mockClock,
+			ExpireAfterWrite: time.Minute,
+			Hooks:            recorder.hooks(),
+			Load: func(key interface{}) (interface{}, error) {
+				mockClock.Add(time.Second)
+				if key.(int) < 0 {
+					return nil, errTestLoadFailed
+				}
+				return fmt.Sprint(key), nil
+			},
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		defer func() {
+			recorder.events = nil
+		}()
+
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(-1)
+		require.Error(t, err)
+		cache.Put(2, "b")
+		mockClock.Add(time.Minute + 1)
+		_, err = cache.Get(2)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{
+			"miss 1",
+			"load start 1",
+			"load end 1=1 false 1s",
+			"hit 1=1",
+			"miss -1",
+			"load start -1",
+			"load end -1=<nil> true 1s",
+			"put 2=b",
+			"expire 2=b",
+			"miss 2",
+			"load start 2",
+			"load end 2=2 false 1s",
+		}, recorder.events)
+	})
+}
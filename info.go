@@ -0,0 +1,47 @@
+package loadingcache
+
+import "time"
+
+// CacheInfo describes a cache. It is the JSON representation of caches published
+// with PublishExpvar, and served by the admin package.
+type CacheInfo struct {
+	// Name is the name the cache is known by, if any
+	Name string `json:"name,omitempty"`
+
+	Size   int        `json:"size"`
+	Weight int64      `json:"weight"`
+	Config ConfigInfo `json:"config"`
+
+	// Stats are the stats of the cache, where evictions are broken down for every
+	// removal reason, including those without any evictions.
+	Stats StatsSnapshot `json:"stats"`
+}
+
+// ConfigInfo is the live configuration of a cache, as reported by its Policy
+type ConfigInfo struct {
+	MaxSize          int32         `json:"max_size"`
+	ExpireAfterWrite time.Duration `json:"expire_after_write_ns"`
+	ExpireAfterRead  time.Duration `json:"expire_after_read_ns"`
+}
+
+// DescribeCache returns the current description of a cache, with a given name.
+func DescribeCache(name string, cache Cache) CacheInfo {
+	policy := cache.Policy()
+	info := CacheInfo{
+		Name:   name,
+		Size:   cache.Len(),
+		Weight: cache.Weight(),
+		Config: ConfigInfo{
+			MaxSize:          policy.MaximumSize(),
+			ExpireAfterWrite: policy.ExpireAfterWrite(),
+			ExpireAfterRead:  policy.ExpireAfterRead(),
+		},
+		Stats: cache.StatsSnapshot(),
+	}
+	evictions := make(map[RemovalReason]EvictionStats, len(removalReasons))
+	for _, reason := range removalReasons {
+		evictions[reason] = info.Stats.EvictionsByReason[reason]
+	}
+	info.Stats.EvictionsByReason = evictions
+	return info
+}
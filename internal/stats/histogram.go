@@ -0,0 +1,177 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// subBucketBits is the number of bits used to subdivide each power of two.
+	// With 3 bits, each power of two is split into 8 buckets, which bounds the
+	// relative error of any reported value to 12.5%.
+	subBucketBits  = 3
+	subBucketCount = 1 << subBucketBits
+
+	// HistogramBucketCount is the number of buckets required to cover all non-negative int64 values
+	HistogramBucketCount = (64 - subBucketBits + 1) * subBucketCount
+)
+
+// Histogram is a fixed memory, log bucketed histogram of durations.
+//
+// Values below 8ns are recorded exactly, while larger values are recorded in buckets
+// whose width is proportional to their magnitude.
+//
+// All recording and reading functions are thread-safe, and do not take any locks.
+type Histogram struct {
+	buckets [HistogramBucketCount]atomic.Int64
+	max     atomic.Int64
+	sum     atomic.Int64
+}
+
+// Record adds a duration to the histogram. Negative durations are recorded as zero.
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	h.buckets[bucketIndex(uint64(v))].Add(1)
+	h.sum.Add(v)
+	for {
+		current := h.max.Load()
+		if v <= current || h.max.CompareAndSwap(current, v) {
+			return
+		}
+	}
+}
+
+// Reset clears all recorded values.
+func (h *Histogram) Reset() {
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+	h.max.Store(0)
+	h.sum.Store(0)
+}
+
+// Snapshot returns a point in time copy of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	var s HistogramSnapshot
+	for i := range h.buckets {
+		count := h.buckets[i].Load()
+		s.Counts[i] = count
+		s.Count += count
+	}
+	s.Max = time.Duration(h.max.Load())
+	s.Sum = time.Duration(h.sum.Load())
+	return s
+}
+
+// HistogramSnapshot is an immutable copy of a Histogram.
+type HistogramSnapshot struct {
+	Counts [HistogramBucketCount]int64
+	Count  int64
+	Max    time.Duration
+	Sum    time.Duration
+}
+
+// Plus returns the merge of two histogram snapshots.
+func (s HistogramSnapshot) Plus(other HistogramSnapshot) HistogramSnapshot {
+	for i := range s.Counts {
+		s.Counts[i] += other.Counts[i]
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+	return s
+}
+
+// Minus returns the values recorded in s but not in other, which must be an earlier
+// snapshot of the same histogram.
+//
+// Since the exact maximum of the difference is unknown, it is approximated by the upper
+// bound of its highest bucket.
+func (s HistogramSnapshot) Minus(other HistogramSnapshot) HistogramSnapshot {
+	var highest = -1
+	for i := range s.Counts {
+		s.Counts[i] -= other.Counts[i]
+		if s.Counts[i] > 0 {
+			highest = i
+		}
+	}
+	s.Count -= other.Count
+	s.Sum -= other.Sum
+	if highest < 0 {
+		s.Max = 0
+	} else if upper := time.Duration(bucketUpperBound(highest)); upper < s.Max {
+		s.Max = upper
+	}
+	return s
+}
+
+// Quantile returns an estimate of the value below which a given fraction of the
+// recorded values fall. The fraction must be between 0 and 1.
+//
+// The estimate is the upper bound of the bucket containing the quantile, capped at the
+// maximum recorded value. If the histogram is empty, it returns zero.
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count <= 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, count := range s.Counts {
+		seen += count
+		if seen >= rank {
+			if upper := time.Duration(bucketUpperBound(i)); upper < s.Max {
+				return upper
+			}
+			return s.Max
+		}
+	}
+	return s.Max
+}
+
+// CountAtOrBelow returns the number of recorded values which are known to be
+// at or below a given duration. Values sharing a bucket with it are only
+// counted if the whole bucket is at or below it.
+func (s HistogramSnapshot) CountAtOrBelow(d time.Duration) int64 {
+	var count int64
+	for i, bucketCount := range s.Counts {
+		if bucketUpperBound(i) > int64(d) {
+			break
+		}
+		count += bucketCount
+	}
+	return count
+}
+
+// bucketIndex returns the index of the bucket which holds a given value.
+func bucketIndex(v uint64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	exponent := bits.Len64(v) - 1 - subBucketBits
+	mantissa := (v >> exponent) & (subBucketCount - 1)
+	return (exponent+1)*subBucketCount + int(mantissa)
+}
+
+// bucketUpperBound returns the largest value held by a given bucket.
+func bucketUpperBound(index int) int64 {
+	if index < subBucketCount {
+		return int64(index)
+	}
+	exponent := index/subBucketCount - 1
+	mantissa := uint64(index % subBucketCount)
+	upper := ((subBucketCount+mantissa+1)<<exponent - 1)
+	if upper > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(upper)
+}
@@ -0,0 +1,87 @@
+package stats_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramEmpty(t *testing.T) {
+	h := &stats.Histogram{}
+	s := h.Snapshot()
+	require.Equal(t, int64(0), s.Count)
+	require.Equal(t, time.Duration(0), s.Max)
+	require.Equal(t, time.Duration(0), s.Quantile(0.99))
+}
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := &stats.Histogram{}
+	// Small values are recorded exactly
+	for i := 0; i < 8; i++ {
+		h.Record(time.Duration(i))
+	}
+	s := h.Snapshot()
+	require.Equal(t, time.Duration(3), s.Quantile(0.5))
+	require.Equal(t, time.Duration(7), s.Quantile(1))
+
+	h = &stats.Histogram{}
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	s = h.Snapshot()
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		expected := float64(time.Duration(q*1000) * time.Microsecond)
+		actual := float64(s.Quantile(q))
+		require.GreaterOrEqual(t, actual, expected)
+		require.InEpsilon(t, expected, actual, 0.125)
+	}
+	require.Equal(t, time.Millisecond, s.Quantile(1))
+	require.Equal(t, time.Millisecond, s.Max)
+	require.Equal(t, 500500*time.Microsecond, s.Sum)
+
+	require.Equal(t, int64(0), s.CountAtOrBelow(0))
+	require.Equal(t, int64(1000), s.CountAtOrBelow(time.Second))
+	below := float64(s.CountAtOrBelow(500 * time.Microsecond))
+	require.LessOrEqual(t, below, float64(500))
+	require.InEpsilon(t, 500, below, 0.125)
+}
+
+func TestHistogramExtremes(t *testing.T) {
+	h := &stats.Histogram{}
+	h.Record(-time.Second)
+	h.Record(time.Duration(math.MaxInt64))
+	s := h.Snapshot()
+	require.Equal(t, int64(2), s.Count)
+	require.Equal(t, time.Duration(0), s.Quantile(0.5))
+	require.Equal(t, time.Duration(math.MaxInt64), s.Quantile(1))
+}
+
+func TestHistogramPlusMinus(t *testing.T) {
+	h1 := &stats.Histogram{}
+	h2 := &stats.Histogram{}
+	for i := 0; i < 99; i++ {
+		h1.Record(time.Millisecond)
+	}
+	h2.Record(time.Second)
+
+	sum := h1.Snapshot().Plus(h2.Snapshot())
+	require.Equal(t, int64(100), sum.Count)
+	require.Equal(t, time.Second, sum.Max)
+	require.InEpsilon(t, float64(time.Millisecond), float64(sum.Quantile(0.99)), 0.125)
+	require.Equal(t, time.Second, sum.Quantile(1))
+
+	before := h1.Snapshot()
+	h1.Record(time.Microsecond)
+	delta := h1.Snapshot().Minus(before)
+	require.Equal(t, int64(1), delta.Count)
+	require.Equal(t, time.Microsecond, delta.Sum)
+	require.InEpsilon(t, float64(time.Microsecond), float64(delta.Max), 0.125)
+	require.InEpsilon(t, float64(time.Microsecond), float64(delta.Quantile(0.5)), 0.125)
+
+	empty := before.Minus(before)
+	require.Equal(t, int64(0), empty.Count)
+	require.Equal(t, time.Duration(0), empty.Max)
+}
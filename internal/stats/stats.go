@@ -1,168 +1,245 @@
 package stats
 
 import (
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// EvictionReason identifies the cause of an eviction.
+//
+// It mirrors the public RemovalReason, which cannot be referenced here
+// without introducing a cyclic import.
+type EvictionReason int
+
+const (
+	// EvictionReasonExplicit means the entry was explicitly invalidated
+	EvictionReasonExplicit EvictionReason = iota
+
+	// EvictionReasonReplaced means the entry was replaced by a new one
+	EvictionReasonReplaced
+
+	// EvictionReasonExpired means the entry expired
+	EvictionReasonExpired
+
+	// EvictionReasonSize means the entry was removed due to the cache size
+	EvictionReasonSize
+
+	// EvictionReasonCount is the number of known eviction reasons
+	EvictionReasonCount
+)
+
 // InternalStats is an internal stats recorder
 //
-// All recording functions are thread-safe.
+// All recording and reading functions are thread-safe, and do not take any locks.
+//
+// A nil *InternalStats is valid, and does not record anything. This allows disabling
+// stats with the lowest possible overhead.
+//
+// Recording functions take the time of the event, which drives the windowed counters.
 type InternalStats struct {
-	evictionCount    int64
-	hitCount         int64
-	missCount        int64
-	loadSuccessCount int64
-	loadErrorCount   int64
-	loadTotalTime    time.Duration
+	evictionCount    [EvictionReasonCount]atomic.Int64
+	evictionWeight   [EvictionReasonCount]atomic.Int64
+	hitCount         atomic.Int64
+	missCount        atomic.Int64
+	loadSuccessCount atomic.Int64
+	loadErrorCount   atomic.Int64
+	loadTotalTime    atomic.Int64
+	panicCount       atomic.Int64
 
-	statsLock sync.RWMutex
+	loadSuccessLatency Histogram
+	loadErrorLatency   Histogram
+
+	window Window
 }
 
-// Eviction increments the number of evictions
-func (s *InternalStats) Eviction() {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.evictionCount++
+// Eviction increments the number of evictions with a given reason, and their total weight
+func (s *InternalStats) Eviction(now time.Time, reason EvictionReason, weight int64) {
+	if s == nil {
+		return
+	}
+	s.evictionCount[reason].Add(1)
+	s.evictionWeight[reason].Add(weight)
+	s.window.Record(now, WindowEvictions)
 }
 
 // Hit increments the number of hits
-func (s *InternalStats) Hit() {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.hitCount++
+func (s *InternalStats) Hit(now time.Time) {
+	if s == nil {
+		return
+	}
+	s.hitCount.Add(1)
+	s.window.Record(now, WindowHits)
 }
 
 // Miss increments the number of misses
-func (s *InternalStats) Miss() {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.missCount++
+func (s *InternalStats) Miss(now time.Time) {
+	if s == nil {
+		return
+	}
+	s.missCount.Add(1)
+	s.window.Record(now, WindowMisses)
 }
 
-// LoadSuccess increments the number of success loads
-func (s *InternalStats) LoadSuccess() {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.loadSuccessCount++
+// LoadSuccess increments the number of success loads, and records their load time
+func (s *InternalStats) LoadSuccess(now time.Time, loadTime time.Duration) {
+	if s == nil {
+		return
+	}
+	s.loadSuccessCount.Add(1)
+	s.loadTotalTime.Add(int64(loadTime))
+	s.loadSuccessLatency.Record(loadTime)
+	s.window.Record(now, WindowLoadSuccesses)
 }
 
-// LoadError increments the number of error loads
-func (s *InternalStats) LoadError() {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.loadErrorCount++
+// LoadError increments the number of error loads, and records their load time
+func (s *InternalStats) LoadError(now time.Time, loadTime time.Duration) {
+	if s == nil {
+		return
+	}
+	s.loadErrorCount.Add(1)
+	s.loadTotalTime.Add(int64(loadTime))
+	s.loadErrorLatency.Record(loadTime)
+	s.window.Record(now, WindowLoadErrors)
 }
 
-// LoadTime increments the total load time
-func (s *InternalStats) LoadTime(loadTime time.Duration) {
-	s.statsLock.Lock()
-	defer s.statsLock.Unlock()
-	s.loadTotalTime += loadTime
+// Panic increments the number of panics
+func (s *InternalStats) Panic() {
+	if s == nil {
+		return
+	}
+	s.panicCount.Add(1)
 }
 
 // EvictionCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) EvictionCount() int64 {
-	return s.evictionCount
+	var total int64
+	for reason := EvictionReason(0); reason < EvictionReasonCount; reason++ {
+		total += s.EvictionCountByReason(reason)
+	}
+	return total
+}
+
+// EvictionCountByReason is the number of times an entry has been evicted with a given reason
+func (s *InternalStats) EvictionCountByReason(reason EvictionReason) int64 {
+	return s.evictionCount[reason].Load()
+}
+
+// EvictionWeightByReason is the total weight of the entries evicted with a given reason
+func (s *InternalStats) EvictionWeightByReason(reason EvictionReason) int64 {
+	return s.evictionWeight[reason].Load()
 }
 
 // HitCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) HitCount() int64 {
-	return s.hitCount
+	return s.hitCount.Load()
 }
 
 // HitRate implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) HitRate() float64 {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-
-	requestCount := s.hitCount + s.missCount
+	hitCount := s.HitCount()
+	requestCount := hitCount + s.MissCount()
 	if requestCount == 0 {
 		return 1
 	}
-	return float64(s.hitCount) / float64(requestCount)
+	return float64(hitCount) / float64(requestCount)
 }
 
 // MissCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) MissCount() int64 {
-	return s.missCount
+	return s.missCount.Load()
 }
 
 // MissRate implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) MissRate() float64 {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-
-	requestCount := s.hitCount + s.missCount
+	missCount := s.MissCount()
+	requestCount := s.HitCount() + missCount
 	if requestCount == 0 {
 		return 0
 	}
-	return float64(s.missCount) / float64(requestCount)
+	return float64(missCount) / float64(requestCount)
 }
 
 // RequestCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) RequestCount() int64 {
-	return s.hitCount + s.missCount
+	return s.HitCount() + s.MissCount()
 }
 
 // LoadSuccessCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) LoadSuccessCount() int64 {
-	return s.loadSuccessCount
+	return s.loadSuccessCount.Load()
 }
 
 // LoadErrorCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) LoadErrorCount() int64 {
-	return s.loadErrorCount
+	return s.loadErrorCount.Load()
 }
 
 // LoadErrorRate implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) LoadErrorRate() float64 {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-
-	totalLoads := s.loadSuccessCount + s.loadErrorCount
+	loadErrorCount := s.LoadErrorCount()
+	totalLoads := s.LoadSuccessCount() + loadErrorCount
 	if totalLoads == 0 {
 		return 0
 	}
-	return float64(s.loadErrorCount) / float64(totalLoads)
+	return float64(loadErrorCount) / float64(totalLoads)
 }
 
 // LoadCount implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) LoadCount() int64 {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-	return s.loadSuccessCount + s.loadErrorCount
+	return s.LoadSuccessCount() + s.LoadErrorCount()
 }
 
 // LoadTotalTime implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) LoadTotalTime() time.Duration {
-	return s.loadTotalTime
+	return time.Duration(s.loadTotalTime.Load())
 }
 
 // AverageLoadPenalty implements the Stats interface. Refer to its documentation for more details
 func (s *InternalStats) AverageLoadPenalty() time.Duration {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-
-	totalLoads := s.loadSuccessCount + s.loadErrorCount
+	totalLoads := s.LoadCount()
 	if totalLoads == 0 {
 		return 0
 	}
-	return s.loadTotalTime / time.Duration(totalLoads)
-}
-
-// Add adds up two stats stores
-func (s *InternalStats) Add(s2 *InternalStats) *InternalStats {
-	s.statsLock.RLock()
-	defer s.statsLock.RUnlock()
-	s2.statsLock.RLock()
-	defer s2.statsLock.RUnlock()
-	return &InternalStats{
-		evictionCount:    s.evictionCount + s2.evictionCount,
-		hitCount:         s.hitCount + s2.hitCount,
-		missCount:        s.missCount + s2.missCount,
-		loadSuccessCount: s.loadSuccessCount + s2.loadSuccessCount,
-		loadErrorCount:   s.loadErrorCount + s2.loadErrorCount,
-		loadTotalTime:    s.loadTotalTime + s2.loadTotalTime,
+	return s.LoadTotalTime() / time.Duration(totalLoads)
+}
+
+// PanicCount implements the Stats interface. Refer to its documentation for more details
+func (s *InternalStats) PanicCount() int64 {
+	return s.panicCount.Load()
+}
+
+// LoadSuccessLatency returns the distribution of the load times of successful loads
+func (s *InternalStats) LoadSuccessLatency() HistogramSnapshot {
+	return s.loadSuccessLatency.Snapshot()
+}
+
+// LoadErrorLatency returns the distribution of the load times of failed loads
+func (s *InternalStats) LoadErrorLatency() HistogramSnapshot {
+	return s.loadErrorLatency.Snapshot()
+}
+
+// Window returns the counters recorded over the duration which ends at a given time.
+// See Window.Counts for details.
+func (s *InternalStats) Window(now time.Time, d time.Duration) WindowCounts {
+	return s.window.Counts(now, d)
+}
+
+// Reset clears all the recorded stats.
+//
+// Counters are cleared one at a time, so calls recorded concurrently with a reset
+// may be partially kept.
+func (s *InternalStats) Reset() {
+	for reason := range s.evictionCount {
+		s.evictionCount[reason].Store(0)
+		s.evictionWeight[reason].Store(0)
 	}
+	s.hitCount.Store(0)
+	s.missCount.Store(0)
+	s.loadSuccessCount.Store(0)
+	s.loadErrorCount.Store(0)
+	s.loadTotalTime.Store(0)
+	s.panicCount.Store(0)
+	s.loadSuccessLatency.Reset()
+	s.loadErrorLatency.Reset()
+	s.window.Reset()
 }
@@ -1,63 +1,134 @@
 package stats_test
 
 import (
+	"sync"
 	"testing"
 	"time"
 
-	"github.com/Hartimer/loadingcache"
 	"github.com/Hartimer/loadingcache/internal/stats"
 	"github.com/stretchr/testify/require"
 )
 
-// Validate that the internal stats implementation respects the public interface.
-//
-// We do this check here to avoid cyclic imports.
-var _ loadingcache.Stats = &stats.InternalStats{}
-
 func TestBasicIncrementers(t *testing.T) {
 	s := &stats.InternalStats{}
+	now := time.Now()
 	for i := int64(1); i <= 10; i++ {
-		s.Hit()
+		s.Hit(now)
 		require.Equal(t, i, s.HitCount())
-		s.Miss()
+		s.Miss(now)
 		require.Equal(t, i, s.MissCount())
-		s.LoadSuccess()
+		s.LoadSuccess(now, time.Minute)
 		require.Equal(t, i, s.LoadSuccessCount())
-		s.LoadError()
+		s.LoadError(now, time.Minute)
 		require.Equal(t, i, s.LoadErrorCount())
-		s.Eviction()
+		s.Eviction(now, stats.EvictionReasonSize, 2)
 		require.Equal(t, i, s.EvictionCount())
-		s.LoadTime(time.Minute)
-		require.Equal(t, time.Duration(i)*time.Minute, s.LoadTotalTime())
+		require.Equal(t, i, s.EvictionCountByReason(stats.EvictionReasonSize))
+		require.Equal(t, 2*i, s.EvictionWeightByReason(stats.EvictionReasonSize))
+		require.Equal(t, time.Duration(2*i)*time.Minute, s.LoadTotalTime())
+		s.Panic()
+		require.Equal(t, i, s.PanicCount())
 	}
 }
 
 func TestRates(t *testing.T) {
 	s := &stats.InternalStats{}
+	now := time.Now()
 
 	// Hit rate
 	for i := 0; i < 12; i++ {
-		s.Hit()
+		s.Hit(now)
 		if i%4 == 0 {
-			s.Miss()
+			s.Miss(now)
 		}
 	}
 	require.Equal(t, float64(0.8), s.HitRate())
 	require.Equal(t, float64(0.2), s.MissRate())
 
 	// Load rate
+	// Each load takes 80 seconds
+	loadTime := time.Minute + 20*time.Second
 	for i := 0; i < 12; i++ {
-		s.LoadSuccess()
+		s.LoadSuccess(now, loadTime)
 		if i%4 == 0 {
-			s.LoadError()
+			s.LoadError(now, loadTime)
 		}
 	}
 	require.Equal(t, float64(0.2), s.LoadErrorRate())
 	require.Equal(t, int64(15), s.LoadCount())
 
 	// Average load penalty
-	// We know the total amount of loads is 15 (see above). Let's say altogether they
-	// took 20 minutes.
-	s.LoadTime(20 * time.Minute)
-	require.Equal(t, time.Minute+(20*time.Second), s.AverageLoadPenalty())
+	require.Equal(t, 20*time.Minute, s.LoadTotalTime())
+	require.Equal(t, loadTime, s.AverageLoadPenalty())
+}
+
+func TestConcurrentRecording(t *testing.T) {
+	s := &stats.InternalStats{}
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Hit(now)
+				s.Miss(now)
+				s.Eviction(now, stats.EvictionReasonExpired, 1)
+				// Reading concurrently with writes must be safe
+				_ = s.HitRate()
+				_ = s.EvictionCount()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(1000), s.HitCount())
+	require.Equal(t, int64(1000), s.MissCount())
+	require.Equal(t, int64(1000), s.EvictionCount())
+}
+
+func TestNilStats(t *testing.T) {
+	var s *stats.InternalStats
+	now := time.Now()
+	require.NotPanics(t, func() {
+		s.Hit(now)
+		s.Miss(now)
+		s.Eviction(now, stats.EvictionReasonExpired, 1)
+		s.LoadSuccess(now, time.Minute)
+		s.LoadError(now, time.Minute)
+		s.Panic()
+	})
+}
+
+func TestEvictionsByReason(t *testing.T) {
+	s := &stats.InternalStats{}
+	now := time.Now()
+	s.Eviction(now, stats.EvictionReasonSize, 3)
+	s.Eviction(now, stats.EvictionReasonExpired, 1)
+	s.Eviction(now, stats.EvictionReasonExpired, 2)
+
+	require.Equal(t, int64(3), s.EvictionCount())
+	require.Equal(t, int64(1), s.EvictionCountByReason(stats.EvictionReasonSize))
+	require.Equal(t, int64(3), s.EvictionWeightByReason(stats.EvictionReasonSize))
+	require.Equal(t, int64(2), s.EvictionCountByReason(stats.EvictionReasonExpired))
+	require.Equal(t, int64(3), s.EvictionWeightByReason(stats.EvictionReasonExpired))
+	require.Equal(t, int64(0), s.EvictionCountByReason(stats.EvictionReasonReplaced))
+}
+
+func TestLoadLatency(t *testing.T) {
+	s := &stats.InternalStats{}
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		s.LoadSuccess(now, time.Duration(i)*time.Millisecond)
+	}
+	s.LoadError(now, time.Second)
+
+	success := s.LoadSuccessLatency()
+	require.Equal(t, int64(100), success.Count)
+	require.Equal(t, 100*time.Millisecond, success.Max)
+	require.InEpsilon(t, float64(50*time.Millisecond), float64(success.Quantile(0.5)), 0.125)
+	require.InEpsilon(t, float64(99*time.Millisecond), float64(success.Quantile(0.99)), 0.125)
+
+	failure := s.LoadErrorLatency()
+	require.Equal(t, int64(1), failure.Count)
+	require.Equal(t, time.Second, failure.Quantile(0.5))
 }
@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// WindowResolution is the duration covered by each bucket of a Window
+	WindowResolution = 5 * time.Second
+
+	// MaxWindow is the longest duration a Window can report on
+	MaxWindow = 15 * time.Minute
+
+	windowBucketCount = int64(MaxWindow / WindowResolution)
+)
+
+// WindowCounter identifies a counter tracked by a Window
+type WindowCounter int
+
+const (
+	// WindowHits counts hits
+	WindowHits WindowCounter = iota
+
+	// WindowMisses counts misses
+	WindowMisses
+
+	// WindowLoadSuccesses counts successful loads
+	WindowLoadSuccesses
+
+	// WindowLoadErrors counts failed loads
+	WindowLoadErrors
+
+	// WindowEvictions counts evictions
+	WindowEvictions
+
+	windowCounterCount
+)
+
+// WindowCounts holds the counters recorded during a window
+type WindowCounts [windowCounterCount]int64
+
+// Window keeps track of counters over the most recent MaxWindow, using a ring of buckets
+// which each cover WindowResolution.
+//
+// Time is provided by the caller, so windows can be driven by a mock clock. The zero
+// value is ready to use.
+//
+// Buckets are recycled lazily when they are first written to in a new period. Counters
+// recorded concurrently with a bucket being recycled may be lost, so windowed counts are
+// approximate.
+type Window struct {
+	buckets [windowBucketCount]windowBucket
+}
+
+type windowBucket struct {
+	// period identifies which WindowResolution sized period the bucket holds.
+	// It is offset by one, so zero means the bucket was never written to.
+	period   atomic.Int64
+	counters [windowCounterCount]atomic.Int64
+}
+
+// Record increments a counter in the bucket of a given time
+func (w *Window) Record(now time.Time, counter WindowCounter) {
+	period := periodOf(now)
+	bucket := &w.buckets[period%windowBucketCount]
+	if current := bucket.period.Load(); current != period {
+		if current > period || !bucket.period.CompareAndSwap(current, period) {
+			// The clock moved backwards, or another call recycled the bucket.
+			if bucket.period.Load() != period {
+				return
+			}
+		} else {
+			for i := range bucket.counters {
+				bucket.counters[i].Store(0)
+			}
+		}
+	}
+	bucket.counters[counter].Add(1)
+}
+
+// Counts returns the counters recorded over the duration which ends at a given time,
+// rounded up to WindowResolution and capped at MaxWindow.
+func (w *Window) Counts(now time.Time, d time.Duration) WindowCounts {
+	var counts WindowCounts
+	periods := int64((d + WindowResolution - 1) / WindowResolution)
+	if periods > windowBucketCount {
+		periods = windowBucketCount
+	}
+	current := periodOf(now)
+	for i := range w.buckets {
+		bucket := &w.buckets[i]
+		period := bucket.period.Load()
+		if period <= current-periods || period > current {
+			continue
+		}
+		for counter := range bucket.counters {
+			counts[counter] += bucket.counters[counter].Load()
+		}
+	}
+	return counts
+}
+
+// Reset clears all buckets
+func (w *Window) Reset() {
+	for i := range w.buckets {
+		w.buckets[i].period.Store(0)
+		for counter := range w.buckets[i].counters {
+			w.buckets[i].counters[counter].Store(0)
+		}
+	}
+}
+
+func periodOf(t time.Time) int64 {
+	return t.UnixNano()/int64(WindowResolution) + 1
+}
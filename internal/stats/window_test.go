@@ -0,0 +1,67 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowCounts(t *testing.T) {
+	w := &stats.Window{}
+	now := time.Unix(0, 0)
+
+	w.Record(now, stats.WindowHits)
+	w.Record(now, stats.WindowMisses)
+	now = now.Add(2 * time.Minute)
+	w.Record(now, stats.WindowHits)
+	w.Record(now, stats.WindowEvictions)
+
+	lastMinute := w.Counts(now, time.Minute)
+	require.Equal(t, int64(1), lastMinute[stats.WindowHits])
+	require.Equal(t, int64(0), lastMinute[stats.WindowMisses])
+	require.Equal(t, int64(1), lastMinute[stats.WindowEvictions])
+
+	lastFiveMinutes := w.Counts(now, 5*time.Minute)
+	require.Equal(t, int64(2), lastFiveMinutes[stats.WindowHits])
+	require.Equal(t, int64(1), lastFiveMinutes[stats.WindowMisses])
+
+	// Windows are capped at the maximum window
+	require.Equal(t, lastFiveMinutes, w.Counts(now, time.Hour))
+
+	// Once the buckets are reused, old counts are dropped
+	now = now.Add(stats.MaxWindow)
+	w.Record(now, stats.WindowLoadErrors)
+	counts := w.Counts(now, stats.MaxWindow)
+	require.Equal(t, int64(0), counts[stats.WindowHits])
+	require.Equal(t, int64(1), counts[stats.WindowLoadErrors])
+
+	// Records in the past of a reused bucket are dropped
+	w.Record(now.Add(-stats.MaxWindow), stats.WindowHits)
+	require.Equal(t, counts, w.Counts(now, stats.MaxWindow))
+
+	w.Reset()
+	require.Equal(t, stats.WindowCounts{}, w.Counts(now, stats.MaxWindow))
+}
+
+func TestReset(t *testing.T) {
+	s := &stats.InternalStats{}
+	now := time.Now()
+	s.Hit(now)
+	s.Miss(now)
+	s.LoadSuccess(now, time.Second)
+	s.LoadError(now, time.Second)
+	s.Eviction(now, stats.EvictionReasonSize, 1)
+	s.Panic()
+	require.Equal(t, int64(1), s.Window(now, time.Minute)[stats.WindowHits])
+
+	s.Reset()
+	require.Equal(t, int64(0), s.RequestCount())
+	require.Equal(t, int64(0), s.LoadCount())
+	require.Equal(t, time.Duration(0), s.LoadTotalTime())
+	require.Equal(t, int64(0), s.EvictionCount())
+	require.Equal(t, int64(0), s.PanicCount())
+	require.Equal(t, int64(0), s.LoadSuccessLatency().Count)
+	require.Equal(t, stats.WindowCounts{}, s.Window(now, stats.MaxWindow))
+}
@@ -0,0 +1,84 @@
+package loadingcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// closedBit flags that a lifecycle was closed. The remaining bits
+// hold the number of in-flight operations.
+const closedBit = 1 << 62
+
+// lifecycle tracks the in-flight operations of a cache, so it
+// can be shut down gracefully.
+//
+// It does not use any locks, keeping the overhead on the hot path low.
+type lifecycle struct {
+	state int64
+
+	drained     chan struct{}
+	drainedOnce sync.Once
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{drained: make(chan struct{})}
+}
+
+// begin registers an in-flight operation. It returns false if the
+// lifecycle is closed, in which case the operation must not proceed.
+//
+// Every successful call to begin must be matched with a call to end.
+func (l *lifecycle) begin() bool {
+	if atomic.AddInt64(&l.state, 1)&closedBit != 0 {
+		l.end()
+		return false
+	}
+	return true
+}
+
+// end marks an in-flight operation as completed.
+func (l *lifecycle) end() {
+	if atomic.AddInt64(&l.state, -1) == closedBit {
+		l.markDrained()
+	}
+}
+
+// close prevents new operations from starting. It returns false
+// if the lifecycle was already closed.
+func (l *lifecycle) close() bool {
+	for {
+		state := atomic.LoadInt64(&l.state)
+		if state&closedBit != 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&l.state, state, state|closedBit) {
+			if state == 0 {
+				l.markDrained()
+			}
+			return true
+		}
+	}
+}
+
+// isClosed checks if the lifecycle is closed, without registering an operation.
+func (l *lifecycle) isClosed() bool {
+	return atomic.LoadInt64(&l.state)&closedBit != 0
+}
+
+func (l *lifecycle) markDrained() {
+	l.drainedOnce.Do(func() {
+		close(l.drained)
+	})
+}
+
+// wait blocks until the lifecycle is closed and all in-flight operations
+// have completed, or the context is done.
+func (l *lifecycle) wait(ctx context.Context) error {
+	select {
+	case <-l.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
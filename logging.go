@@ -0,0 +1,168 @@
+package loadingcache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// LogLevels are the levels at which cache events are logged. Any particular level
+// may be nil, in which case its default is used.
+type LogLevels struct {
+	// Load is the level of successful loads. Defaults to slog.LevelDebug.
+	Load slog.Leveler
+
+	// LoadError is the level of failed loads. Defaults to slog.LevelWarn.
+	LoadError slog.Leveler
+
+	// Eviction is the level of evicted entries. Defaults to slog.LevelDebug.
+	Eviction slog.Leveler
+
+	// Sweep is the level of background eviction sweeps. Defaults to slog.LevelDebug.
+	Sweep slog.Leveler
+
+	// Lifecycle is the level of the cache being created and shut down.
+	// Defaults to slog.LevelInfo.
+	Lifecycle slog.Leveler
+}
+
+// withDefaults returns the levels, replacing the unset ones with their default
+func (l LogLevels) withDefaults() LogLevels {
+	if l.Load == nil {
+		l.Load = slog.LevelDebug
+	}
+	if l.LoadError == nil {
+		l.LoadError = slog.LevelWarn
+	}
+	if l.Eviction == nil {
+		l.Eviction = slog.LevelDebug
+	}
+	if l.Sweep == nil {
+		l.Sweep = slog.LevelDebug
+	}
+	if l.Lifecycle == nil {
+		l.Lifecycle = slog.LevelInfo
+	}
+	return l
+}
+
+// cacheLogger logs cache events. A nil *cacheLogger discards all events,
+// so caches without a logger only pay for a nil check.
+type cacheLogger struct {
+	logger    *slog.Logger
+	levels    LogLevels
+	renderKey func(key interface{}) string
+
+	// shard is set for the shards of a sharded cache, which leave
+	// lifecycle events to the sharded cache itself
+	shard bool
+}
+
+// newCacheLogger returns a logger for the given options, or nil if there is no logger.
+func newCacheLogger(options CacheOptions) *cacheLogger {
+	if options.Logger == nil {
+		return nil
+	}
+	return &cacheLogger{
+		logger:    options.Logger,
+		levels:    options.LogLevels.withDefaults(),
+		renderKey: options.KeyRenderer,
+	}
+}
+
+// forShard returns a logger for the shard with the given index
+func (l *cacheLogger) forShard(index int) *cacheLogger {
+	if l == nil {
+		return nil
+	}
+	shardLogger := *l
+	shardLogger.logger = l.logger.With(slog.Int("shard", index))
+	shardLogger.shard = true
+	return &shardLogger
+}
+
+func (l *cacheLogger) log(level slog.Leveler, msg string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !l.logger.Enabled(ctx, level.Level()) {
+		return
+	}
+	l.logger.LogAttrs(ctx, level.Level(), msg, attrs...)
+}
+
+func (l *cacheLogger) key(key interface{}) slog.Attr {
+	if l.renderKey != nil {
+		return slog.String("key", l.renderKey(key))
+	}
+	return slog.Any("key", key)
+}
+
+// The following functions log the corresponding events, if there is a logger.
+
+func (l *cacheLogger) loaded(key interface{}, loadTime time.Duration) {
+	if l == nil {
+		return
+	}
+	l.log(l.levels.Load, "cache value loaded", l.key(key), slog.Duration("load_time", loadTime))
+}
+
+func (l *cacheLogger) loadFailed(key interface{}, err error, loadTime time.Duration) {
+	if l == nil {
+		return
+	}
+	l.log(l.levels.LoadError, "cache value failed to load",
+		l.key(key), slog.Duration("load_time", loadTime), loadFailureCause(err))
+}
+
+// loadFailureCause describes why a load failed. Load errors print their key,
+// so only their cause is logged, which keeps the key rendered by the KeyRenderer.
+func loadFailureCause(err error) slog.Attr {
+	var panicErr *LoadPanicError
+	if errors.As(err, &panicErr) {
+		return slog.Any("panic", panicErr.Value)
+	}
+	var loadErr *LoadError
+	if errors.As(err, &loadErr) {
+		return slog.Any("error", loadErr.Err)
+	}
+	return slog.Any("error", err)
+}
+
+func (l *cacheLogger) evicted(key interface{}, reason RemovalReason) {
+	if l == nil {
+		return
+	}
+	l.log(l.levels.Eviction, "cache entry evicted", l.key(key), slog.String("reason", string(reason)))
+}
+
+func (l *cacheLogger) swept(evicted int, duration time.Duration) {
+	if l == nil {
+		return
+	}
+	l.log(l.levels.Sweep, "cache background eviction completed",
+		slog.Int("evicted", evicted), slog.Duration("duration", duration))
+}
+
+func (l *cacheLogger) created(options CacheOptions) {
+	if l == nil || l.shard {
+		return
+	}
+	l.log(l.levels.Lifecycle, "cache created",
+		slog.Int("shards", options.ShardCount),
+		slog.Int("max_size", int(options.MaxSize)),
+		slog.Int64("max_weight", options.MaxWeight),
+		slog.Duration("expire_after_write", options.ExpireAfterWrite),
+		slog.Duration("expire_after_read", options.ExpireAfterRead),
+		slog.Duration("background_evict_frequency", options.BackgroundEvictFrequency))
+}
+
+func (l *cacheLogger) shutDown(err error) {
+	if l == nil || l.shard {
+		return
+	}
+	if err != nil {
+		l.log(l.levels.Lifecycle, "cache shutdown interrupted", slog.Any("error", err))
+		return
+	}
+	l.log(l.levels.Lifecycle, "cache shut down")
+}
@@ -0,0 +1,176 @@
+package loadingcache_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer collects log lines, and is safe to use concurrently
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSpace(b.buf.String()), "\n")
+}
+
+// newTestLogger returns a logger writing lines without timestamps
+func newTestLogger(level slog.Level) (*slog.Logger, *logBuffer) {
+	buf := &logBuffer{}
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(handler), buf
+}
+
+func TestLogging(t *testing.T) {
+	mockClock := clock.NewMock()
+	logger, buf := newTestLogger(slog.LevelDebug)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Clock:            mockClock,
+		ExpireAfterWrite: time.Minute,
+		MaxSize:          2,
+		Logger:           logger,
+		Load: func(key interface{}) (interface{}, error) {
+			mockClock.Add(time.Second)
+			if key.(int) < 0 {
+				return nil, errTestLoadFailed
+			}
+			return key, nil
+		},
+	})
+
+	_, err := cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(-1)
+	require.Error(t, err)
+	cache.Put(1, 2)
+	mockClock.Add(time.Minute + 1)
+	cache.Put(3, 3)
+	cache.Close()
+	cache.Close()
+
+	require.Equal(t, []string{
+		`level=INFO msg="cache created" shards=0 max_size=2 max_weight=0 expire_after_write=1m0s expire_after_read=0s background_evict_frequency=0s`,
+		`level=DEBUG msg="cache value loaded" key=1 load_time=1s`,
+		`level=WARN msg="cache value failed to load" key=-1 load_time=1s error="` + errTestLoadFailed.Error() + `"`,
+		`level=DEBUG msg="cache entry evicted" key=1 reason=REPLACED`,
+		`level=DEBUG msg="cache entry evicted" key=1 reason=EXPIRED`,
+		`level=INFO msg="cache shut down"`,
+	}, buf.lines())
+}
+
+func TestLoggingSharded(t *testing.T) {
+	logger, buf := newTestLogger(slog.LevelDebug)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		ShardCount:   2,
+		HashCodeFunc: intHashCodeFunc,
+		Logger:       logger,
+		Load: func(key interface{}) (interface{}, error) {
+			return key, nil
+		},
+	})
+	_, err := cache.Get(3)
+	require.NoError(t, err)
+	cache.Close()
+	cache.Close()
+
+	lines := buf.lines()
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], `msg="cache created" shards=2`)
+	require.Contains(t, lines[1], `msg="cache value loaded" shard=1 key=3`)
+	require.Equal(t, `level=INFO msg="cache shut down"`, lines[2])
+}
+
+func TestLoggingBackgroundEvict(t *testing.T) {
+	mockClock := clock.NewMock()
+	logger, buf := newTestLogger(slog.LevelDebug)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Clock:                    mockClock,
+		ExpireAfterWrite:         time.Minute,
+		BackgroundEvictFrequency: time.Minute,
+		Logger:                   logger,
+	})
+	defer cache.Close()
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+
+	// The clock is advanced until the background go routine has started, and swept
+	// the entries once they expired
+	require.Eventually(t, func() bool {
+		mockClock.Add(time.Minute)
+		for _, line := range buf.lines() {
+			if line == `level=DEBUG msg="cache background eviction completed" evicted=2 duration=0s` {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+}
+
+func TestLogLevelsAndKeyRenderer(t *testing.T) {
+	logger, buf := newTestLogger(slog.LevelInfo)
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Clock:  clock.NewMock(),
+		Logger: logger,
+		LogLevels: loadingcache.LogLevels{
+			Load:      slog.LevelInfo,
+			Lifecycle: slog.LevelDebug,
+		},
+		KeyRenderer: func(key interface{}) string {
+			return "<redacted>"
+		},
+		Load: func(key interface{}) (interface{}, error) {
+			if key == "secret panic" {
+				panic("cannot load")
+			}
+			if key != "secret" {
+				return nil, errTestLoadFailed
+			}
+			return key, nil
+		},
+	})
+	defer cache.Close()
+
+	_, err := cache.Get("secret")
+	require.NoError(t, err)
+	cache.Put("secret", "value")
+	_, err = cache.Get("secret failure")
+	require.Error(t, err)
+	_, err = cache.Get("secret panic")
+	require.Error(t, err)
+
+	// Creation and evictions are logged below the logger's level
+	lines := buf.lines()
+	require.Equal(t, []string{
+		`level=INFO msg="cache value loaded" key=<redacted> load_time=0s`,
+		`level=WARN msg="cache value failed to load" key=<redacted> load_time=0s error="` + errTestLoadFailed.Error() + `"`,
+		`level=WARN msg="cache value failed to load" key=<redacted> load_time=0s panic="cannot load"`,
+	}, lines)
+	for _, line := range lines {
+		require.NotContains(t, line, "secret")
+	}
+}
@@ -0,0 +1,82 @@
+package loadingcache
+
+import "context"
+
+// Operations are the cache operations which can be decorated by a Middleware.
+type Operations struct {
+	// Get looks up a key. It backs both Get and GetContext, the former
+	// using context.Background().
+	Get func(ctx context.Context, key interface{}) (interface{}, error)
+
+	// Put adds a value to the cache
+	Put func(key interface{}, value interface{})
+
+	// Invalidate removes keys from the cache
+	Invalidate func(key interface{}, keys ...interface{})
+
+	// Load is the loading function, which is nil if the cache has none
+	Load LoadContextFunc
+}
+
+// Middleware decorates cache operations, e.g. to add logging or auditing.
+//
+// It receives the next operations in the chain, and returns the operations to use
+// instead. Operations which are returned unchanged are not decorated. Middlewares are
+// applied once, when the cache is created.
+type Middleware func(next Operations) Operations
+
+// middlewareCache routes operations through a chain of middlewares.
+//
+// Since it wraps the whole cache, middlewares apply the same way to
+// sharded and unsharded caches, as well as typed caches built on them.
+type middlewareCache struct {
+	Cache
+
+	operations Operations
+}
+
+// newMiddlewareCache applies the configured middlewares, and builds the cache they decorate.
+func newMiddlewareCache(options CacheOptions) Cache {
+	c := &middlewareCache{}
+	// The decorated cache can only be built once the loading function is decorated,
+	// so the innermost operations look it up when called.
+	operations := Operations{
+		Get: func(ctx context.Context, key interface{}) (interface{}, error) {
+			return c.Cache.GetContext(ctx, key)
+		},
+		Put: func(key interface{}, value interface{}) {
+			c.Cache.Put(key, value)
+		},
+		Invalidate: func(key interface{}, keys ...interface{}) {
+			c.Cache.Invalidate(key, keys...)
+		},
+		Load: options.loader(),
+	}
+	// Middlewares are applied in reverse, so the first one is the outermost
+	for i := len(options.Middlewares) - 1; i >= 0; i-- {
+		operations = options.Middlewares[i](operations)
+	}
+	c.operations = operations
+
+	options.Load = nil
+	options.LoadWithResult = nil
+	options.LoadContext = operations.Load
+	c.Cache = newBaseCache(options)
+	return c
+}
+
+func (m *middlewareCache) Get(key interface{}) (interface{}, error) {
+	return m.operations.Get(context.Background(), key)
+}
+
+func (m *middlewareCache) GetContext(ctx context.Context, key interface{}) (interface{}, error) {
+	return m.operations.Get(ctx, key)
+}
+
+func (m *middlewareCache) Put(key interface{}, value interface{}) {
+	m.operations.Put(key, value)
+}
+
+func (m *middlewareCache) Invalidate(key interface{}, keys ...interface{}) {
+	m.operations.Invalidate(key, keys...)
+}
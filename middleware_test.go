@@ -0,0 +1,105 @@
+package loadingcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+// loggingMiddleware records every operation it sees, prefixed by a name
+func loggingMiddleware(name string, log *[]string) loadingcache.Middleware {
+	return func(next loadingcache.Operations) loadingcache.Operations {
+		return loadingcache.Operations{
+			Get: func(ctx context.Context, key interface{}) (interface{}, error) {
+				*log = append(*log, fmt.Sprintf("%s get %v", name, key))
+				return next.Get(ctx, key)
+			},
+			Put: func(key interface{}, value interface{}) {
+				*log = append(*log, fmt.Sprintf("%s put %v", name, key))
+				next.Put(key, value)
+			},
+			Invalidate: func(key interface{}, keys ...interface{}) {
+				*log = append(*log, fmt.Sprintf("%s invalidate %v", name, key))
+				next.Invalidate(key, keys...)
+			},
+			Load: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+				*log = append(*log, fmt.Sprintf("%s load %v", name, key))
+				return next.Load(ctx, key)
+			},
+		}
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	var log []string
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Load: (&testLoadFunc{}).LoadFunc,
+			Middlewares: []loadingcache.Middleware{
+				loggingMiddleware("outer", &log),
+				loggingMiddleware("inner", &log),
+			},
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		defer func() {
+			log = nil
+		}()
+
+		val, err := cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, "1", val)
+		_, err = cache.GetContext(context.Background(), 1)
+		require.NoError(t, err)
+		cache.Put(2, "b")
+		cache.Invalidate(2)
+
+		require.Equal(t, []string{
+			"outer get 1",
+			"inner get 1",
+			"outer load 1",
+			"inner load 1",
+			"outer get 1",
+			"inner get 1",
+			"outer put 2",
+			"inner put 2",
+			"outer invalidate 2",
+			"inner invalidate 2",
+		}, log)
+	})
+}
+
+func TestMiddlewarePassThrough(t *testing.T) {
+	errBlocked := errors.New("blocked")
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Middlewares: []loadingcache.Middleware{
+			// Only decorates Get, blocking negative keys
+			func(next loadingcache.Operations) loadingcache.Operations {
+				get := next.Get
+				next.Get = func(ctx context.Context, key interface{}) (interface{}, error) {
+					if key.(int) < 0 {
+						return nil, errBlocked
+					}
+					return get(ctx, key)
+				}
+				return next
+			},
+		},
+	})
+	defer cache.Close()
+
+	cache.Put(1, "a")
+	val, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, "a", val)
+
+	_, err = cache.Get(-1)
+	require.True(t, errors.Is(err, errBlocked))
+
+	// Operations which are not decorated reach the cache
+	cache.Invalidate(1)
+	require.Equal(t, 0, cache.Len())
+}
@@ -0,0 +1,278 @@
+package loadingcache
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// CacheOption describes an option that can configure the cache.
+//
+// Options are applied in order by NewWithOptions, and return an error
+// if the provided value is invalid.
+type CacheOption func(*CacheOptions) error
+
+// NewWithOptions instantiates a new cache configured by functional options.
+//
+// Unlike New, it returns an error if the resulting configuration is invalid,
+// instead of panicking. The error can be checked with errors.Is(err, ErrInvalidOptions).
+func NewWithOptions(opts ...CacheOption) (Cache, error) {
+	var options CacheOptions
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, err
+		}
+	}
+	return newCache(options)
+}
+
+// WithClock configures the clock used by the cache. See CacheOptions.Clock.
+func WithClock(c clock.Clock) CacheOption {
+	return func(options *CacheOptions) error {
+		if c == nil {
+			return invalidOptions("clock must not be nil")
+		}
+		options.Clock = c
+		return nil
+	}
+}
+
+// WithExpireAfterWrite configures the cache to expire entries after a given duration
+// after writing. See CacheOptions.ExpireAfterWrite.
+func WithExpireAfterWrite(d time.Duration) CacheOption {
+	return func(options *CacheOptions) error {
+		if d <= 0 {
+			return invalidOptions("expire after write must be positive")
+		}
+		options.ExpireAfterWrite = d
+		return nil
+	}
+}
+
+// WithExpireAfterRead configures the cache to expire entries after a given duration
+// after reading. See CacheOptions.ExpireAfterRead.
+func WithExpireAfterRead(d time.Duration) CacheOption {
+	return func(options *CacheOptions) error {
+		if d <= 0 {
+			return invalidOptions("expire after read must be positive")
+		}
+		options.ExpireAfterRead = d
+		return nil
+	}
+}
+
+// WithLoader configures a loading function. See CacheOptions.Load.
+func WithLoader(load LoadFunc) CacheOption {
+	return func(options *CacheOptions) error {
+		if load == nil {
+			return invalidOptions("load function must not be nil")
+		}
+		options.Load = load
+		return nil
+	}
+}
+
+// WithLoaderWithResult configures a loading function which returns a LoadResult.
+// See CacheOptions.LoadWithResult.
+func WithLoaderWithResult(load LoadWithResultFunc) CacheOption {
+	return func(options *CacheOptions) error {
+		if load == nil {
+			return invalidOptions("load function must not be nil")
+		}
+		options.LoadWithResult = load
+		return nil
+	}
+}
+
+// WithLoaderContext configures a loading function which receives the lookup's context.
+// See CacheOptions.LoadContext.
+func WithLoaderContext(load LoadContextFunc) CacheOption {
+	return func(options *CacheOptions) error {
+		if load == nil {
+			return invalidOptions("load function must not be nil")
+		}
+		options.LoadContext = load
+		return nil
+	}
+}
+
+// WithMaxSize limits the number of entries allowed in the cache. See CacheOptions.MaxSize.
+func WithMaxSize(size int32) CacheOption {
+	return func(options *CacheOptions) error {
+		if size <= 0 {
+			return invalidOptions("max size must be positive")
+		}
+		options.MaxSize = size
+		return nil
+	}
+}
+
+// WithMaxWeight limits the total weight of the entries allowed in the cache.
+// See CacheOptions.MaxWeight.
+func WithMaxWeight(weight int64) CacheOption {
+	return func(options *CacheOptions) error {
+		if weight <= 0 {
+			return invalidOptions("max weight must be positive")
+		}
+		options.MaxWeight = weight
+		return nil
+	}
+}
+
+// WithRemovalListeners adds removal listeners. See CacheOptions.RemovalListeners.
+func WithRemovalListeners(listeners ...RemovalListener) CacheOption {
+	return func(options *CacheOptions) error {
+		for _, listener := range listeners {
+			if listener == nil {
+				return invalidOptions("removal listener must not be nil")
+			}
+		}
+		options.RemovalListeners = append(options.RemovalListeners, listeners...)
+		return nil
+	}
+}
+
+// WithShards configures how many shards will be used by the cache, and the function
+// used to assign keys to shards. If hashCodeFunc is nil, DefaultHashCode is used.
+// See CacheOptions.ShardCount.
+func WithShards(count int, hashCodeFunc func(key interface{}) int) CacheOption {
+	return func(options *CacheOptions) error {
+		if count <= 0 {
+			return invalidOptions("shard count must be positive")
+		}
+		options.ShardCount = count
+		options.HashCodeFunc = hashCodeFunc
+		return nil
+	}
+}
+
+// WithBackgroundEvict configures a background go routine which evicts expired
+// entries with the given frequency. See CacheOptions.BackgroundEvictFrequency.
+func WithBackgroundEvict(frequency time.Duration) CacheOption {
+	return func(options *CacheOptions) error {
+		if frequency <= 0 {
+			return invalidOptions("background evict frequency must be positive")
+		}
+		options.BackgroundEvictFrequency = frequency
+		return nil
+	}
+}
+
+// WithTTLJitter randomly brings forward the write expiry of each entry by up to
+// the given duration. See CacheOptions.TTLJitter.
+func WithTTLJitter(jitter time.Duration) CacheOption {
+	return func(options *CacheOptions) error {
+		if jitter <= 0 {
+			return invalidOptions("ttl jitter must be positive")
+		}
+		options.TTLJitter = jitter
+		return nil
+	}
+}
+
+// WithTTLJitterFraction randomly brings forward the write expiry of each entry by up to
+// the given fraction of its TTL. See CacheOptions.TTLJitterFraction.
+func WithTTLJitterFraction(fraction float64) CacheOption {
+	return func(options *CacheOptions) error {
+		if fraction <= 0 || fraction > 1 {
+			return invalidOptions("ttl jitter fraction must be between 0 and 1")
+		}
+		options.TTLJitterFraction = fraction
+		return nil
+	}
+}
+
+// WithJitterSource configures the random source used to compute jitter.
+// See CacheOptions.JitterSource.
+func WithJitterSource(source func() float64) CacheOption {
+	return func(options *CacheOptions) error {
+		if source == nil {
+			return invalidOptions("jitter source must not be nil")
+		}
+		options.JitterSource = source
+		return nil
+	}
+}
+
+// WithErrorHandler configures a handler for errors which cannot be returned to a caller.
+// See CacheOptions.ErrorHandler.
+func WithErrorHandler(handler func(error)) CacheOption {
+	return func(options *CacheOptions) error {
+		if handler == nil {
+			return invalidOptions("error handler must not be nil")
+		}
+		options.ErrorHandler = handler
+		return nil
+	}
+}
+
+// WithoutStats disables stats recording. See CacheOptions.DisableStats.
+func WithoutStats() CacheOption {
+	return func(options *CacheOptions) error {
+		options.DisableStats = true
+		return nil
+	}
+}
+
+// WithStatsRecorder sends stats events to a custom recorder. See CacheOptions.StatsRecorder.
+func WithStatsRecorder(recorder StatsRecorder) CacheOption {
+	return func(options *CacheOptions) error {
+		if recorder == nil {
+			return invalidOptions("stats recorder must not be nil")
+		}
+		options.StatsRecorder = recorder
+		return nil
+	}
+}
+
+// WithHooks configures functions called on cache events. See CacheOptions.Hooks.
+func WithHooks(hooks Hooks) CacheOption {
+	return func(options *CacheOptions) error {
+		options.Hooks = hooks
+		return nil
+	}
+}
+
+// WithMiddlewares adds middlewares decorating cache operations. See CacheOptions.Middlewares.
+func WithMiddlewares(middlewares ...Middleware) CacheOption {
+	return func(options *CacheOptions) error {
+		for _, middleware := range middlewares {
+			if middleware == nil {
+				return invalidOptions("middleware must not be nil")
+			}
+		}
+		options.Middlewares = append(options.Middlewares, middlewares...)
+		return nil
+	}
+}
+
+// WithLogger configures the logger of cache events. See CacheOptions.Logger.
+func WithLogger(logger *slog.Logger) CacheOption {
+	return func(options *CacheOptions) error {
+		if logger == nil {
+			return invalidOptions("logger must not be nil")
+		}
+		options.Logger = logger
+		return nil
+	}
+}
+
+// WithLogLevels configures the level of each logged event. See CacheOptions.LogLevels.
+func WithLogLevels(levels LogLevels) CacheOption {
+	return func(options *CacheOptions) error {
+		options.LogLevels = levels
+		return nil
+	}
+}
+
+// WithKeyRenderer configures how keys are logged. See CacheOptions.KeyRenderer.
+func WithKeyRenderer(renderer func(key interface{}) string) CacheOption {
+	return func(options *CacheOptions) error {
+		if renderer == nil {
+			return invalidOptions("key renderer must not be nil")
+		}
+		options.KeyRenderer = renderer
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package loadingcache_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	mockClock := clock.NewMock()
+	cache, err := loadingcache.NewWithOptions(
+		loadingcache.WithClock(mockClock),
+		loadingcache.WithMaxSize(1),
+		loadingcache.WithExpireAfterWrite(time.Minute),
+		loadingcache.WithShards(2, intHashCodeFunc),
+		loadingcache.WithLoader(func(key interface{}) (interface{}, error) {
+			return fmt.Sprint(key), nil
+		}),
+	)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	val, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, "1", val)
+	require.Equal(t, int64(1), cache.Stats().LoadSuccessCount())
+
+	// The value expires, so it must be loaded again
+	mockClock.Add(time.Minute + 1)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), cache.Stats().LoadSuccessCount())
+}
+
+func TestNewWithOptionsValidation(t *testing.T) {
+	testCases := map[string][]loadingcache.CacheOption{
+		"negative max size":         {loadingcache.WithMaxSize(-1)},
+		"zero shards":               {loadingcache.WithShards(0, intHashCodeFunc)},
+		"nil loader":                {loadingcache.WithLoader(nil)},
+		"jitter fraction too large": {loadingcache.WithTTLJitterFraction(2)},
+		"nil stats recorder":        {loadingcache.WithStatsRecorder(nil)},
+		"conflicting jitter": {
+			loadingcache.WithTTLJitter(time.Second),
+			loadingcache.WithTTLJitterFraction(0.5),
+		},
+		"nil context loader": {loadingcache.WithLoaderContext(nil)},
+		"nil logger":         {loadingcache.WithLogger(nil)},
+		"nil key renderer":   {loadingcache.WithKeyRenderer(nil)},
+		"nil middleware":     {loadingcache.WithMiddlewares(nil)},
+		"conflicting loaders": {
+			loadingcache.WithLoader((&testLoadFunc{}).LoadFunc),
+			loadingcache.WithLoaderWithResult(func(key interface{}) (loadingcache.LoadResult, error) {
+				return loadingcache.LoadResult{}, nil
+			}),
+		},
+	}
+	for name, opts := range testCases {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			cache, err := loadingcache.NewWithOptions(opts...)
+			require.Nil(t, cache)
+			require.True(t, errors.Is(err, loadingcache.ErrInvalidOptions))
+		})
+	}
+}
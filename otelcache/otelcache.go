@@ -0,0 +1,169 @@
+// Package otelcache instruments loading caches with OpenTelemetry traces and metrics.
+package otelcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of spans and metrics
+const instrumentationName = "github.com/Hartimer/loadingcache/otelcache"
+
+// Attribute keys used on spans and metrics
+const (
+	// NameKey is the name of the cache
+	NameKey = attribute.Key("cache.name")
+
+	// ResultKey is the outcome of a lookup, one of the Result values
+	ResultKey = attribute.Key("cache.result")
+
+	// HitKey is whether a lookup found a cached value
+	HitKey = attribute.Key("cache.hit")
+
+	// SharedLoadKey is whether a lookup used a value loaded by a concurrent lookup
+	SharedLoadKey = attribute.Key("cache.load.shared")
+
+	// ErrorKey is whether a lookup failed. Not finding a key is not considered a failure.
+	ErrorKey = attribute.Key("cache.error")
+)
+
+// Outcomes of a lookup, used as values of ResultKey
+const (
+	// ResultHit means a cached value was found
+	ResultHit = "hit"
+
+	// ResultSharedLoad means the value loaded by a concurrent lookup was used
+	ResultSharedLoad = "shared_load"
+
+	// ResultLoad means the value was loaded
+	ResultLoad = "load"
+
+	// ResultMiss means no value was found, and there was no loading function
+	ResultMiss = "miss"
+)
+
+// Options configures the instrumentation.
+type Options struct {
+	// Name identifies the cache in spans and metrics
+	Name string
+
+	// TracerProvider creates the tracer used to record spans.
+	//
+	// If not specified, the global provider is used.
+	TracerProvider trace.TracerProvider
+
+	// MeterProvider creates the meter used to record metrics.
+	//
+	// If not specified, the global provider is used.
+	MeterProvider metric.MeterProvider
+}
+
+// Wrap instruments a cache. The returned cache records a span for every lookup made
+// with Get or GetContext, and the following metrics:
+//
+//   - cache.lookups, the number of lookups by result
+//   - cache.load.duration, the time spent loading values
+//
+// Lookups made with GetContext propagate the span through the context, so it is
+// available to a CacheOptions.LoadContext loading function. All other operations
+// are passed through to the wrapped cache.
+func Wrap(cache loadingcache.Cache, options Options) (loadingcache.Cache, error) {
+	tracerProvider := options.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	meterProvider := options.MeterProvider
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	meter := meterProvider.Meter(instrumentationName)
+	lookups, err := meter.Int64Counter("cache.lookups",
+		metric.WithDescription("Number of cache lookups, by result."),
+		metric.WithUnit("{lookup}"))
+	if err != nil {
+		return nil, err
+	}
+	loadDuration, err := meter.Float64Histogram("cache.load.duration",
+		metric.WithDescription("Time spent loading values."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedCache{
+		Cache:        cache,
+		name:         options.Name,
+		tracer:       tracerProvider.Tracer(instrumentationName),
+		lookups:      lookups,
+		loadDuration: loadDuration,
+	}, nil
+}
+
+type instrumentedCache struct {
+	loadingcache.Cache
+
+	name         string
+	tracer       trace.Tracer
+	lookups      metric.Int64Counter
+	loadDuration metric.Float64Histogram
+}
+
+func (c *instrumentedCache) Get(key interface{}) (interface{}, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *instrumentedCache) GetContext(ctx context.Context, key interface{}) (interface{}, error) {
+	ctx, span := c.tracer.Start(ctx, "loadingcache.Get",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(NameKey.String(c.name)))
+	defer span.End()
+
+	// The hooks are called by the lookup on this goroutine, so there is no need to synchronize
+	result := ResultMiss
+	var loadStart time.Time
+	ctx = loadingcache.WithTrace(ctx, &loadingcache.Trace{
+		Hit: func(interface{}) {
+			result = ResultHit
+		},
+		SharedLoad: func(interface{}) {
+			result = ResultSharedLoad
+		},
+		LoadStart: func(interface{}) {
+			result = ResultLoad
+			loadStart = time.Now()
+		},
+		LoadDone: func(_ interface{}, err error) {
+			c.loadDuration.Record(ctx, time.Since(loadStart).Seconds(), metric.WithAttributes(
+				NameKey.String(c.name),
+				ErrorKey.Bool(err != nil),
+			))
+		},
+	})
+
+	val, err := c.Cache.GetContext(ctx, key)
+
+	failed := err != nil && !errors.Is(err, loadingcache.ErrKeyNotFound)
+	attributes := []attribute.KeyValue{
+		NameKey.String(c.name),
+		ResultKey.String(result),
+		ErrorKey.Bool(failed),
+	}
+	c.lookups.Add(ctx, 1, metric.WithAttributes(attributes...))
+	span.SetAttributes(
+		ResultKey.String(result),
+		HitKey.Bool(result == ResultHit || result == ResultSharedLoad),
+		SharedLoadKey.Bool(result == ResultSharedLoad),
+	)
+	if failed {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return val, err
+}
@@ -0,0 +1,191 @@
+package otelcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/Hartimer/loadingcache/otelcache"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errLoadFailed = errors.New("load failed")
+
+func setup(t *testing.T, options loadingcache.CacheOptions) (loadingcache.Cache, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	cache, err := otelcache.Wrap(loadingcache.New(options), otelcache.Options{
+		Name:           "test",
+		TracerProvider: tracerProvider,
+		MeterProvider:  meterProvider,
+	})
+	require.NoError(t, err)
+	t.Cleanup(cache.Close)
+	return cache, exporter, reader
+}
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestSpans(t *testing.T) {
+	var loaderSpan trace.SpanContext
+	cache, exporter, _ := setup(t, loadingcache.CacheOptions{
+		LoadContext: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+			loaderSpan = trace.SpanContextFromContext(ctx)
+			if key.(int) < 0 {
+				return loadingcache.LoadResult{}, errLoadFailed
+			}
+			return loadingcache.LoadResult{Value: key}, nil
+		},
+	})
+
+	_, err := cache.GetContext(context.Background(), 1)
+	require.NoError(t, err)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(-1)
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+
+	// The span is propagated to the loading function, which was last called by the failed load
+	require.Equal(t, spans[2].SpanContext.SpanID(), loaderSpan.SpanID())
+
+	load := spanAttributes(spans[0])
+	require.Equal(t, "loadingcache.Get", spans[0].Name)
+	require.Equal(t, "test", load[otelcache.NameKey].AsString())
+	require.Equal(t, otelcache.ResultLoad, load[otelcache.ResultKey].AsString())
+	require.False(t, load[otelcache.HitKey].AsBool())
+	require.False(t, load[otelcache.SharedLoadKey].AsBool())
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+
+	hit := spanAttributes(spans[1])
+	require.Equal(t, otelcache.ResultHit, hit[otelcache.ResultKey].AsString())
+	require.True(t, hit[otelcache.HitKey].AsBool())
+
+	require.Equal(t, codes.Error, spans[2].Status.Code)
+	require.Len(t, spans[2].Events, 1)
+}
+
+func TestMissIsNotAnError(t *testing.T) {
+	cache, exporter, _ := setup(t, loadingcache.CacheOptions{})
+
+	_, err := cache.Get(1)
+	require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, otelcache.ResultMiss, spanAttributes(spans[0])[otelcache.ResultKey].AsString())
+	require.Equal(t, codes.Unset, spans[0].Status.Code)
+}
+
+func TestMetrics(t *testing.T) {
+	cache, _, reader := setup(t, loadingcache.CacheOptions{
+		Load: func(key interface{}) (interface{}, error) {
+			if key.(int) < 0 {
+				return nil, errLoadFailed
+			}
+			return key, nil
+		},
+	})
+
+	_, err := cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	_, err = cache.Get(-1)
+	require.Error(t, err)
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &data))
+	require.Len(t, data.ScopeMetrics, 1)
+	metrics := map[string]metricdata.Metrics{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	lookups := map[string]int64{}
+	for _, point := range metrics["cache.lookups"].Data.(metricdata.Sum[int64]).DataPoints {
+		result, _ := point.Attributes.Value(otelcache.ResultKey)
+		failed, _ := point.Attributes.Value(otelcache.ErrorKey)
+		name, _ := point.Attributes.Value(otelcache.NameKey)
+		require.Equal(t, "test", name.AsString())
+		if failed.AsBool() {
+			lookups[result.AsString()+" error"] += point.Value
+		} else {
+			lookups[result.AsString()] += point.Value
+		}
+	}
+	require.Equal(t, map[string]int64{
+		otelcache.ResultLoad:            1,
+		otelcache.ResultHit:             1,
+		otelcache.ResultLoad + " error": 1,
+	}, lookups)
+
+	var loadCount uint64
+	for _, point := range metrics["cache.load.duration"].Data.(metricdata.Histogram[float64]).DataPoints {
+		loadCount += point.Count
+	}
+	require.Equal(t, uint64(2), loadCount)
+}
+
+func TestLoaderLookingUpAnotherCache(t *testing.T) {
+	inner := loadingcache.New(loadingcache.CacheOptions{
+		Load: func(key interface{}) (interface{}, error) {
+			return key, nil
+		},
+	})
+	defer inner.Close()
+	inner.Put("cached", "cached")
+
+	cache, exporter, reader := setup(t, loadingcache.CacheOptions{
+		LoadContext: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+			// The inner cache hits once, and loads once
+			if _, err := inner.GetContext(ctx, "cached"); err != nil {
+				return loadingcache.LoadResult{}, err
+			}
+			val, err := inner.GetContext(ctx, key)
+			return loadingcache.LoadResult{Value: val}, err
+		},
+	})
+
+	_, err := cache.GetContext(context.Background(), "loaded")
+	require.NoError(t, err)
+
+	// The inner lookups are not mistaken for the outer one
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	attributes := spanAttributes(spans[0])
+	require.Equal(t, otelcache.ResultLoad, attributes[otelcache.ResultKey].AsString())
+	require.False(t, attributes[otelcache.HitKey].AsBool())
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &data))
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		if m.Name != "cache.load.duration" {
+			continue
+		}
+		var loadCount uint64
+		for _, point := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+			loadCount += point.Count
+		}
+		require.Equal(t, uint64(1), loadCount)
+	}
+}
@@ -0,0 +1,125 @@
+package loadingcache
+
+import "time"
+
+// Policy allows inspecting and changing the configuration of a live cache,
+// e.g. to shrink it under memory pressure, or to lengthen expiries during an incident.
+//
+// If the cache is sharded, settings apply to each shard, the same way
+// as the corresponding CacheOptions.
+type Policy interface {
+	// MaximumSize returns the maximum number of entries allowed in the cache,
+	// or 0 if there is no limit. See CacheOptions.MaxSize.
+	MaximumSize() int32
+
+	// SetMaximumSize changes the maximum number of entries allowed in the cache.
+	// A non-positive size removes the limit.
+	//
+	// If the cache holds more entries than the new limit, entries are evicted
+	// with RemovalReasonSize until it fits.
+	SetMaximumSize(size int32)
+
+	// ExpireAfterWrite returns the duration after which entries expire since
+	// they were written, or 0 if they do not. See CacheOptions.ExpireAfterWrite.
+	ExpireAfterWrite() time.Duration
+
+	// SetExpireAfterWrite changes the duration after which entries expire since
+	// they were written. A non-positive duration disables the expiry.
+	//
+	// The change applies to existing entries as well. Entries loaded with
+	// their own TTL are unaffected.
+	SetExpireAfterWrite(d time.Duration)
+
+	// ExpireAfterRead returns the duration after which entries expire since
+	// they were read, or 0 if they do not. See CacheOptions.ExpireAfterRead.
+	ExpireAfterRead() time.Duration
+
+	// SetExpireAfterRead changes the duration after which entries expire since
+	// they were read. A non-positive duration disables the expiry.
+	//
+	// The change applies to existing entries as well.
+	SetExpireAfterRead(d time.Duration)
+}
+
+// genericPolicy is the policy of a genericCache
+type genericPolicy struct {
+	cache *genericCache
+}
+
+func (p genericPolicy) MaximumSize() int32 {
+	return p.cache.maxSize.Load()
+}
+
+func (p genericPolicy) SetMaximumSize(size int32) {
+	if size < 0 {
+		size = 0
+	}
+	g := p.cache
+	g.writeLock.Lock()
+	defer g.writeLock.Unlock()
+	g.maxSize.Store(size)
+	if size == 0 {
+		return
+	}
+	for g.size > size {
+		g.evictAny()
+	}
+}
+
+func (p genericPolicy) ExpireAfterWrite() time.Duration {
+	return time.Duration(p.cache.expireAfterWrite.Load())
+}
+
+func (p genericPolicy) SetExpireAfterWrite(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	p.cache.expireAfterWrite.Store(int64(d))
+}
+
+func (p genericPolicy) ExpireAfterRead() time.Duration {
+	return time.Duration(p.cache.expireAfterRead.Load())
+}
+
+func (p genericPolicy) SetExpireAfterRead(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	p.cache.expireAfterRead.Store(int64(d))
+}
+
+// shardedPolicy is the policy of a shardedCache.
+// Changes are applied to every shard, while settings are read from the first one.
+type shardedPolicy struct {
+	cache *shardedCache
+}
+
+func (p shardedPolicy) MaximumSize() int32 {
+	return p.cache.shards[0].Policy().MaximumSize()
+}
+
+func (p shardedPolicy) SetMaximumSize(size int32) {
+	for _, shard := range p.cache.shards {
+		shard.Policy().SetMaximumSize(size)
+	}
+}
+
+func (p shardedPolicy) ExpireAfterWrite() time.Duration {
+	return p.cache.shards[0].Policy().ExpireAfterWrite()
+}
+
+func (p shardedPolicy) SetExpireAfterWrite(d time.Duration) {
+	for _, shard := range p.cache.shards {
+		shard.Policy().SetExpireAfterWrite(d)
+	}
+}
+
+func (p shardedPolicy) ExpireAfterRead() time.Duration {
+	return p.cache.shards[0].Policy().ExpireAfterRead()
+}
+
+func (p shardedPolicy) SetExpireAfterRead(d time.Duration) {
+	for _, shard := range p.cache.shards {
+		shard.Policy().SetExpireAfterRead(d)
+	}
+}
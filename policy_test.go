@@ -0,0 +1,91 @@
+package loadingcache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicySettings(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			MaxSize:          10,
+			ExpireAfterWrite: time.Minute,
+			ExpireAfterRead:  2 * time.Minute,
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			policy := cache.Policy()
+			require.Equal(t, int32(10), policy.MaximumSize())
+			require.Equal(t, time.Minute, policy.ExpireAfterWrite())
+			require.Equal(t, 2*time.Minute, policy.ExpireAfterRead())
+
+			policy.SetMaximumSize(20)
+			policy.SetExpireAfterWrite(time.Hour)
+			policy.SetExpireAfterRead(-1)
+			require.Equal(t, int32(20), policy.MaximumSize())
+			require.Equal(t, time.Hour, policy.ExpireAfterWrite())
+			require.Equal(t, time.Duration(0), policy.ExpireAfterRead())
+		})
+}
+
+func TestPolicySetExpireAfterWrite(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: time.Minute,
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			cache.Put(1, 1)
+
+			// Lengthening the expiry applies to existing entries
+			cache.Policy().SetExpireAfterWrite(time.Hour)
+			mockClock.Add(time.Minute + 1)
+			val, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, 1, val)
+
+			// And so does shortening it
+			cache.Policy().SetExpireAfterWrite(time.Minute)
+			_, err = cache.Get(1)
+			require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		})
+}
+
+func TestPolicySetMaximumSize(t *testing.T) {
+	removalReasons := map[loadingcache.RemovalReason]int{}
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			RemovalListeners: []loadingcache.RemovalListener{
+				func(notification loadingcache.RemovalNotification) {
+					removalReasons[notification.Reason]++
+				},
+			},
+		},
+	},
+		func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+			for reason := range removalReasons {
+				delete(removalReasons, reason)
+			}
+			for i := 0; i < 100; i++ {
+				cache.Put(i, i)
+			}
+
+			// Shrinking evicts down to the new limit on every shard
+			cache.Policy().SetMaximumSize(1)
+			var remaining int
+			for i := 0; i < 100; i++ {
+				if _, err := cache.Get(i); err == nil {
+					remaining++
+				}
+			}
+			require.LessOrEqual(t, remaining, 32)
+			require.Equal(t, 100-remaining, removalReasons[loadingcache.RemovalReasonSize])
+			require.Len(t, removalReasons, 1)
+		})
+}
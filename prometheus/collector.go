@@ -0,0 +1,170 @@
+// Package prometheus exports the stats of loading caches as Prometheus metrics.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// removalReasons are exported as the reason label of eviction metrics
+var removalReasons = []loadingcache.RemovalReason{
+	loadingcache.RemovalReasonExplicit,
+	loadingcache.RemovalReasonReplaced,
+	loadingcache.RemovalReasonExpired,
+	loadingcache.RemovalReasonSize,
+}
+
+// CollectorOptions configures a Collector.
+type CollectorOptions struct {
+	// Caches are the caches to export, by name. The name is used as the
+	// cache label of every metric.
+	Caches map[string]loadingcache.Cache
+
+	// Registry provides further caches to export. It is enumerated on every scrape,
+	// so caches registered after the collector was created are exported too.
+	//
+	// Names must not clash with the names in Caches.
+	Registry *loadingcache.Registry
+
+	// LoadDurationBuckets are the upper bounds, in seconds, of the load duration
+	// histogram buckets.
+	//
+	// If not specified, prometheus.DefBuckets is used.
+	LoadDurationBuckets []float64
+}
+
+// Collector is a prometheus.Collector which exports the stats of one or more caches.
+//
+// Stats are read when the collector is scraped, so the caches are not slowed down
+// by the export. The following metrics are exported, all labelled with the cache name:
+//
+//   - loadingcache_hits_total
+//   - loadingcache_misses_total
+//   - loadingcache_loads_total, labelled by result (success or error)
+//   - loadingcache_load_duration_seconds, labelled by result (success or error)
+//   - loadingcache_evictions_total, labelled by reason
+//   - loadingcache_evicted_weight_total, labelled by reason
+//   - loadingcache_panics_total
+//   - loadingcache_entries
+//   - loadingcache_weight
+type Collector struct {
+	caches   map[string]loadingcache.Cache
+	registry *loadingcache.Registry
+	buckets  []float64
+
+	hits          *prom.Desc
+	misses        *prom.Desc
+	loads         *prom.Desc
+	loadDuration  *prom.Desc
+	evictions     *prom.Desc
+	evictedWeight *prom.Desc
+	panics        *prom.Desc
+	entries       *prom.Desc
+	weight        *prom.Desc
+}
+
+var _ prom.Collector = &Collector{}
+
+// NewCollector instantiates a new collector for the given caches.
+func NewCollector(options CollectorOptions) *Collector {
+	caches := make(map[string]loadingcache.Cache, len(options.Caches))
+	for name, cache := range options.Caches {
+		caches[name] = cache
+	}
+	buckets := options.LoadDurationBuckets
+	if len(buckets) == 0 {
+		buckets = prom.DefBuckets
+	}
+	return &Collector{
+		caches:   caches,
+		registry: options.Registry,
+		buckets:  buckets,
+
+		hits: prom.NewDesc("loadingcache_hits_total",
+			"Number of times lookups returned a cached value.",
+			[]string{"cache"}, nil),
+		misses: prom.NewDesc("loadingcache_misses_total",
+			"Number of times lookups found no value and could not load one.",
+			[]string{"cache"}, nil),
+		loads: prom.NewDesc("loadingcache_loads_total",
+			"Number of times values were loaded, by result.",
+			[]string{"cache", "result"}, nil),
+		loadDuration: prom.NewDesc("loadingcache_load_duration_seconds",
+			"Time spent loading values, by result.",
+			[]string{"cache", "result"}, nil),
+		evictions: prom.NewDesc("loadingcache_evictions_total",
+			"Number of evicted entries, by removal reason.",
+			[]string{"cache", "reason"}, nil),
+		evictedWeight: prom.NewDesc("loadingcache_evicted_weight_total",
+			"Total weight of the evicted entries, by removal reason.",
+			[]string{"cache", "reason"}, nil),
+		panics: prom.NewDesc("loadingcache_panics_total",
+			"Number of times a loading function or removal listener panicked.",
+			[]string{"cache"}, nil),
+		entries: prom.NewDesc("loadingcache_entries",
+			"Number of entries in the cache.",
+			[]string{"cache"}, nil),
+		weight: prom.NewDesc("loadingcache_weight",
+			"Total weight of the entries in the cache.",
+			[]string{"cache"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.loads
+	ch <- c.loadDuration
+	ch <- c.evictions
+	ch <- c.evictedWeight
+	ch <- c.panics
+	ch <- c.entries
+	ch <- c.weight
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	for name, cache := range c.caches {
+		c.collect(ch, name, cache)
+	}
+	if c.registry != nil {
+		c.registry.Range(func(name string, cache loadingcache.Cache) bool {
+			c.collect(ch, name, cache)
+			return true
+		})
+	}
+}
+
+func (c *Collector) collect(ch chan<- prom.Metric, name string, cache loadingcache.Cache) {
+	stats := cache.StatsSnapshot()
+
+	ch <- prom.MustNewConstMetric(c.hits, prom.CounterValue, float64(stats.HitCount()), name)
+	ch <- prom.MustNewConstMetric(c.misses, prom.CounterValue, float64(stats.MissCount()), name)
+	ch <- prom.MustNewConstMetric(c.loads, prom.CounterValue, float64(stats.LoadSuccessCount()), name, "success")
+	ch <- prom.MustNewConstMetric(c.loads, prom.CounterValue, float64(stats.LoadErrorCount()), name, "error")
+	ch <- c.histogram(stats.LoadSuccessLatency(), name, "success")
+	ch <- c.histogram(stats.LoadErrorLatency(), name, "error")
+	for _, reason := range removalReasons {
+		ch <- prom.MustNewConstMetric(c.evictions, prom.CounterValue,
+			float64(stats.EvictionCountByReason(reason)), name, string(reason))
+		ch <- prom.MustNewConstMetric(c.evictedWeight, prom.CounterValue,
+			float64(stats.EvictionWeightByReason(reason)), name, string(reason))
+	}
+	ch <- prom.MustNewConstMetric(c.panics, prom.CounterValue, float64(stats.PanicCount()), name)
+	ch <- prom.MustNewConstMetric(c.entries, prom.GaugeValue, float64(cache.Len()), name)
+	ch <- prom.MustNewConstMetric(c.weight, prom.GaugeValue, float64(cache.Weight()), name)
+}
+
+// histogram converts a latency distribution to a Prometheus histogram.
+func (c *Collector) histogram(latency loadingcache.LatencyStats, labelValues ...string) prom.Metric {
+	buckets := make(map[float64]uint64, len(c.buckets))
+	for _, upperBound := range c.buckets {
+		d := time.Duration(upperBound * float64(time.Second))
+		buckets[upperBound] = uint64(latency.CountAtOrBelow(d))
+	}
+	return prom.MustNewConstHistogram(c.loadDuration,
+		uint64(latency.Count), latency.Sum.Seconds(), buckets, labelValues...)
+}
@@ -0,0 +1,145 @@
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/Hartimer/loadingcache/prometheus"
+	"github.com/benbjohnson/clock"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	mockClock := clock.NewMock()
+	cacheA := loadingcache.New(loadingcache.CacheOptions{
+		Clock:   mockClock,
+		MaxSize: 1,
+		Load: func(key interface{}) (interface{}, error) {
+			mockClock.Add(time.Second)
+			return key, nil
+		},
+	})
+	defer cacheA.Close()
+	cacheB := loadingcache.New(loadingcache.CacheOptions{
+		Clock: mockClock,
+	})
+	defer cacheB.Close()
+
+	_, err := cacheA.Get(1)
+	require.NoError(t, err)
+	_, err = cacheA.Get(1)
+	require.NoError(t, err)
+	_, err = cacheA.Get(2)
+	require.NoError(t, err)
+	_, err = cacheB.Get(1)
+	require.Error(t, err)
+
+	collector := prometheus.NewCollector(prometheus.CollectorOptions{
+		Caches: map[string]loadingcache.Cache{
+			"a": cacheA,
+			"b": cacheB,
+		},
+		LoadDurationBuckets: []float64{0.5, 2},
+	})
+	registry := prom.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	expected := `
+# HELP loadingcache_entries Number of entries in the cache.
+# TYPE loadingcache_entries gauge
+loadingcache_entries{cache="a"} 1
+loadingcache_entries{cache="b"} 0
+# HELP loadingcache_evictions_total Number of evicted entries, by removal reason.
+# TYPE loadingcache_evictions_total counter
+loadingcache_evictions_total{cache="a",reason="EXPIRED"} 0
+loadingcache_evictions_total{cache="a",reason="EXPLICIT"} 0
+loadingcache_evictions_total{cache="a",reason="REPLACED"} 0
+loadingcache_evictions_total{cache="a",reason="SIZE"} 1
+loadingcache_evictions_total{cache="b",reason="EXPIRED"} 0
+loadingcache_evictions_total{cache="b",reason="EXPLICIT"} 0
+loadingcache_evictions_total{cache="b",reason="REPLACED"} 0
+loadingcache_evictions_total{cache="b",reason="SIZE"} 0
+# HELP loadingcache_hits_total Number of times lookups returned a cached value.
+# TYPE loadingcache_hits_total counter
+loadingcache_hits_total{cache="a"} 1
+loadingcache_hits_total{cache="b"} 0
+# HELP loadingcache_load_duration_seconds Time spent loading values, by result.
+# TYPE loadingcache_load_duration_seconds histogram
+loadingcache_load_duration_seconds_bucket{cache="a",result="error",le="0.5"} 0
+loadingcache_load_duration_seconds_bucket{cache="a",result="error",le="2"} 0
+loadingcache_load_duration_seconds_bucket{cache="a",result="error",le="+Inf"} 0
+loadingcache_load_duration_seconds_sum{cache="a",result="error"} 0
+loadingcache_load_duration_seconds_count{cache="a",result="error"} 0
+loadingcache_load_duration_seconds_bucket{cache="a",result="success",le="0.5"} 0
+loadingcache_load_duration_seconds_bucket{cache="a",result="success",le="2"} 2
+loadingcache_load_duration_seconds_bucket{cache="a",result="success",le="+Inf"} 2
+loadingcache_load_duration_seconds_sum{cache="a",result="success"} 2
+loadingcache_load_duration_seconds_count{cache="a",result="success"} 2
+loadingcache_load_duration_seconds_bucket{cache="b",result="error",le="0.5"} 0
+loadingcache_load_duration_seconds_bucket{cache="b",result="error",le="2"} 0
+loadingcache_load_duration_seconds_bucket{cache="b",result="error",le="+Inf"} 0
+loadingcache_load_duration_seconds_sum{cache="b",result="error"} 0
+loadingcache_load_duration_seconds_count{cache="b",result="error"} 0
+loadingcache_load_duration_seconds_bucket{cache="b",result="success",le="0.5"} 0
+loadingcache_load_duration_seconds_bucket{cache="b",result="success",le="2"} 0
+loadingcache_load_duration_seconds_bucket{cache="b",result="success",le="+Inf"} 0
+loadingcache_load_duration_seconds_sum{cache="b",result="success"} 0
+loadingcache_load_duration_seconds_count{cache="b",result="success"} 0
+# HELP loadingcache_loads_total Number of times values were loaded, by result.
+# TYPE loadingcache_loads_total counter
+loadingcache_loads_total{cache="a",result="error"} 0
+loadingcache_loads_total{cache="a",result="success"} 2
+loadingcache_loads_total{cache="b",result="error"} 0
+loadingcache_loads_total{cache="b",result="success"} 0
+# HELP loadingcache_misses_total Number of times lookups found no value and could not load one.
+# TYPE loadingcache_misses_total counter
+loadingcache_misses_total{cache="a"} 0
+loadingcache_misses_total{cache="b"} 1
+# HELP loadingcache_weight Total weight of the entries in the cache.
+# TYPE loadingcache_weight gauge
+loadingcache_weight{cache="a"} 1
+loadingcache_weight{cache="b"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"loadingcache_entries",
+		"loadingcache_evictions_total",
+		"loadingcache_hits_total",
+		"loadingcache_load_duration_seconds",
+		"loadingcache_loads_total",
+		"loadingcache_misses_total",
+		"loadingcache_weight",
+	))
+
+	// All the exported metrics are consistent
+	problems, err := testutil.GatherAndLint(registry)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
+
+func TestCollectorRegistry(t *testing.T) {
+	caches := loadingcache.NewRegistry()
+	defer caches.Close()
+	collector := prometheus.NewCollector(prometheus.CollectorOptions{
+		Registry: caches,
+	})
+	registry := prom.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	// Caches registered after the collector was created are exported
+	cache := loadingcache.New(loadingcache.CacheOptions{})
+	caches.MustRegister("late", cache)
+	cache.Put(1, "a")
+
+	expected := `
+# HELP loadingcache_entries Number of entries in the cache.
+# TYPE loadingcache_entries gauge
+loadingcache_entries{cache="late"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"loadingcache_entries",
+	))
+}
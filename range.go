@@ -0,0 +1,74 @@
+package loadingcache
+
+func (g *genericCache) Len() int {
+	if !g.lifecycle.begin() {
+		return 0
+	}
+	defer g.lifecycle.end()
+
+	var length int
+	now := g.Clock.Now()
+	g.rangeData(func(entry *cacheEntry) bool {
+		if !g.isExpired(entry, now) {
+			length++
+		}
+		return true
+	})
+	return length
+}
+
+func (g *genericCache) Weight() int64 {
+	if !g.lifecycle.begin() {
+		return 0
+	}
+	defer g.lifecycle.end()
+
+	var weight int64
+	now := g.Clock.Now()
+	g.rangeData(func(entry *cacheEntry) bool {
+		if !g.isExpired(entry, now) {
+			weight += entry.weight
+		}
+		return true
+	})
+	return weight
+}
+
+func (g *genericCache) Keys() []interface{} {
+	return keys(g)
+}
+
+func (g *genericCache) Range(f func(key interface{}, value interface{}) bool) {
+	rangeValues(g, f)
+}
+
+func (g *genericCache) Snapshot() map[interface{}]interface{} {
+	return snapshot(g)
+}
+
+// keys collects the keys of a cache, based on RangeEntries
+func keys(c Cache) []interface{} {
+	var cacheKeys []interface{}
+	c.RangeEntries(func(entry Entry) bool {
+		cacheKeys = append(cacheKeys, entry.Key)
+		return true
+	})
+	return cacheKeys
+}
+
+// rangeValues iterates over the keys and values of a cache, based on RangeEntries
+func rangeValues(c Cache, f func(key interface{}, value interface{}) bool) {
+	c.RangeEntries(func(entry Entry) bool {
+		return f(entry.Key, entry.Value)
+	})
+}
+
+// snapshot copies the keys and values of a cache, based on RangeEntries
+func snapshot(c Cache) map[interface{}]interface{} {
+	values := map[interface{}]interface{}{}
+	c.RangeEntries(func(entry Entry) bool {
+		values[entry.Key] = entry.Value
+		return true
+	})
+	return values
+}
@@ -0,0 +1,106 @@
+package loadingcache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIteration(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			ExpireAfterWrite: time.Minute,
+		},
+	},
+		func(t *testing.T, ctx context.Context, cache loadingcache.Cache) {
+			mockClock := get(ctx).clock
+			require.Equal(t, 0, cache.Len())
+			require.Equal(t, int64(0), cache.Weight())
+			require.Empty(t, cache.Keys())
+			require.Empty(t, cache.Snapshot())
+
+			// This entry will have expired by the time we iterate
+			cache.Put(0, "expired")
+			mockClock.Add(30 * time.Second)
+			for i := 1; i <= 10; i++ {
+				cache.Put(i, i*10)
+			}
+			mockClock.Add(31 * time.Second)
+
+			require.Equal(t, 10, cache.Len())
+			require.Equal(t, int64(10), cache.Weight())
+			require.ElementsMatch(t, []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, cache.Keys())
+
+			snapshot := cache.Snapshot()
+			require.Len(t, snapshot, 10)
+			for i := 1; i <= 10; i++ {
+				require.Equal(t, i*10, snapshot[i])
+			}
+
+			// The snapshot is a copy
+			cache.InvalidateAll()
+			require.Len(t, snapshot, 10)
+			require.Equal(t, 0, cache.Len())
+		})
+}
+
+func TestRange(t *testing.T) {
+	matrixTest(t, matrixTestOptions{}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		for i := 1; i <= 10; i++ {
+			cache.Put(i, i*10)
+		}
+
+		values := map[interface{}]interface{}{}
+		cache.Range(func(key interface{}, value interface{}) bool {
+			values[key] = value
+			// Calling the cache while iterating is safe
+			cache.Invalidate(key)
+			return true
+		})
+		require.Len(t, values, 10)
+		require.Equal(t, 0, cache.Len())
+
+		// Stopping early
+		cache.Put(1, 1)
+		cache.Put(2, 2)
+		var count int
+		cache.Range(func(key interface{}, value interface{}) bool {
+			count++
+			return false
+		})
+		require.Equal(t, 1, count)
+	})
+}
+
+func TestIterationDuringLoad(t *testing.T) {
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Load: func(key interface{}) (interface{}, error) {
+			close(loading)
+			<-release
+			return key, nil
+		},
+	})
+	defer cache.Close()
+	cache.Put(1, 1)
+
+	loaded := make(chan error)
+	go func() {
+		_, err := cache.Get(2)
+		loaded <- err
+	}()
+	<-loading
+
+	// Iterating does not wait for the load to complete
+	require.Equal(t, 1, cache.Len())
+	require.Equal(t, int64(1), cache.Weight())
+	require.Equal(t, []interface{}{1}, cache.Keys())
+
+	close(release)
+	require.NoError(t, <-loaded)
+	require.Equal(t, 2, cache.Len())
+}
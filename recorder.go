@@ -0,0 +1,107 @@
+package loadingcache
+
+import "time"
+
+// StatsRecorder receives the events which make up the cache stats.
+//
+// A custom recorder can be configured with CacheOptions.StatsRecorder, for example
+// to send counters straight into a metrics system. All methods may be called
+// concurrently, and by every shard of a sharded cache, so implementations must be
+// thread-safe. They are called on the hot path, so they should also be fast.
+//
+// Events sent to a custom recorder are not counted by the built-in stats, so Stats,
+// and the exporters based on it, report zero values.
+type StatsRecorder interface {
+	// RecordHit is called when a lookup returns a cached value
+	RecordHit()
+
+	// RecordMiss is called when a lookup finds no value, and there is nothing to load it with
+	RecordMiss()
+
+	// RecordLoadSuccess is called when a value is successfully loaded
+	RecordLoadSuccess(loadTime time.Duration)
+
+	// RecordLoadError is called when loading a value fails
+	RecordLoadError(loadTime time.Duration)
+
+	// RecordEviction is called when an entry is evicted
+	RecordEviction(reason RemovalReason, weight int64)
+
+	// RecordPanic is called when a loading function or a removal listener panics
+	RecordPanic()
+}
+
+// NoopStatsRecorder is a StatsRecorder which discards all events.
+// Configuring it is equivalent to setting CacheOptions.DisableStats.
+type NoopStatsRecorder struct{}
+
+var _ StatsRecorder = NoopStatsRecorder{}
+
+// RecordHit implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordHit() {}
+
+// RecordMiss implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordMiss() {}
+
+// RecordLoadSuccess implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordLoadSuccess(time.Duration) {}
+
+// RecordLoadError implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordLoadError(time.Duration) {}
+
+// RecordEviction implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordEviction(RemovalReason, int64) {}
+
+// RecordPanic implements the StatsRecorder interface
+func (NoopStatsRecorder) RecordPanic() {}
+
+// The following functions send events to the built-in stats, or to the custom recorder.
+// At most one of them is configured, and neither is when stats are disabled.
+
+func (g *genericCache) recordHit(now time.Time) {
+	g.stats.Hit(now)
+	if g.recorder != nil {
+		g.recorder.RecordHit()
+	}
+}
+
+func (g *genericCache) recordMiss() {
+	if g.stats != nil {
+		g.stats.Miss(g.Clock.Now())
+	}
+	if g.recorder != nil {
+		g.recorder.RecordMiss()
+	}
+}
+
+func (g *genericCache) recordLoadSuccess(now time.Time, loadTime time.Duration) {
+	g.stats.LoadSuccess(now, loadTime)
+	if g.recorder != nil {
+		g.recorder.RecordLoadSuccess(loadTime)
+	}
+}
+
+func (g *genericCache) recordLoadError(now time.Time, loadTime time.Duration) {
+	g.stats.LoadError(now, loadTime)
+	if g.recorder != nil {
+		g.recorder.RecordLoadError(loadTime)
+	}
+}
+
+func (g *genericCache) recordEviction(reason RemovalReason, weight int64) {
+	if g.stats != nil {
+		if statsReason, ok := reason.statsReason(); ok {
+			g.stats.Eviction(g.Clock.Now(), statsReason, weight)
+		}
+	}
+	if g.recorder != nil {
+		g.recorder.RecordEviction(reason, weight)
+	}
+}
+
+func (g *genericCache) recordPanic() {
+	g.stats.Panic()
+	if g.recorder != nil {
+		g.recorder.RecordPanic()
+	}
+}
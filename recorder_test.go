@@ -0,0 +1,145 @@
+package loadingcache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// testStatsRecorder counts the events it receives
+type testStatsRecorder struct {
+	mu            sync.Mutex
+	hits          int
+	misses        int
+	loadSuccesses []time.Duration
+	loadErrors    []time.Duration
+	evictions     map[loadingcache.RemovalReason]int64
+	panics        int
+}
+
+func (r *testStatsRecorder) RecordHit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits++
+}
+
+func (r *testStatsRecorder) RecordMiss() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.misses++
+}
+
+func (r *testStatsRecorder) RecordLoadSuccess(loadTime time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadSuccesses = append(r.loadSuccesses, loadTime)
+}
+
+func (r *testStatsRecorder) RecordLoadError(loadTime time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadErrors = append(r.loadErrors, loadTime)
+}
+
+func (r *testStatsRecorder) RecordEviction(reason loadingcache.RemovalReason, weight int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.evictions == nil {
+		r.evictions = make(map[loadingcache.RemovalReason]int64)
+	}
+	r.evictions[reason] += weight
+}
+
+func (r *testStatsRecorder) RecordPanic() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics++
+}
+
+func TestStatsRecorder(t *testing.T) {
+	for _, shardCount := range []int{1, 4} {
+		mockClock := clock.NewMock()
+		recorder := &testStatsRecorder{}
+		cache, err := loadingcache.NewWithOptions(
+			loadingcache.WithClock(mockClock),
+			loadingcache.WithShards(shardCount, nil),
+			loadingcache.WithStatsRecorder(recorder),
+			loadingcache.WithLoader(func(key interface{}) (interface{}, error) {
+				mockClock.Add(time.Second)
+				if key.(int) < 0 {
+					return nil, errTestLoadFailed
+				}
+				return key, nil
+			}),
+		)
+		require.NoError(t, err)
+
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(-1)
+		require.Error(t, err)
+		cache.Put(1, "a")
+		cache.Close()
+
+		require.Equal(t, 1, recorder.hits)
+		require.Equal(t, []time.Duration{time.Second}, recorder.loadSuccesses)
+		require.Equal(t, []time.Duration{time.Second}, recorder.loadErrors)
+		require.Equal(t, map[loadingcache.RemovalReason]int64{
+			loadingcache.RemovalReasonReplaced: 1,
+		}, recorder.evictions)
+
+		// Events are only sent to the custom recorder
+		require.Equal(t, int64(0), cache.Stats().RequestCount())
+	}
+}
+
+func TestStatsRecorderMiss(t *testing.T) {
+	recorder := &testStatsRecorder{}
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		StatsRecorder: recorder,
+	})
+	defer cache.Close()
+
+	_, err := cache.Get(1)
+	require.Error(t, err)
+	require.Equal(t, 1, recorder.misses)
+}
+
+func TestStatsRecorderPanic(t *testing.T) {
+	recorder := &testStatsRecorder{}
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		StatsRecorder: recorder,
+		Load: func(key interface{}) (interface{}, error) {
+			panic("loader")
+		},
+		RemovalListeners: []loadingcache.RemovalListener{func(loadingcache.RemovalNotification) {
+			panic("listener")
+		}},
+	})
+	defer cache.Close()
+
+	_, err := cache.Get(1)
+	require.Error(t, err)
+	cache.Put(1, "a")
+	// Replacing the value notifies the removal listener
+	cache.Put(1, "b")
+	require.Equal(t, 2, recorder.panics)
+}
+
+func TestNoopStatsRecorder(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		StatsRecorder: loadingcache.NoopStatsRecorder{},
+	})
+	defer cache.Close()
+
+	cache.Put(1, "a")
+	_, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), cache.Stats().RequestCount())
+}
@@ -0,0 +1,161 @@
+package loadingcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultRegistry is the process-wide registry, for services which do not need
+// to keep track of several registries.
+var DefaultRegistry = NewRegistry()
+
+// Registry keeps track of caches by name, so they can be listed, inspected and
+// operated on as a whole, e.g. by exporters and admin endpoints.
+//
+// All functions are thread-safe.
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]Cache
+}
+
+// NewRegistry instantiates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{caches: map[string]Cache{}}
+}
+
+// Register adds a cache under a given name. It returns an error wrapping
+// ErrAlreadyRegistered if the name is already taken.
+func (r *Registry) Register(name string, cache Cache) error {
+	if name == "" {
+		return errors.New("cache name must not be empty")
+	}
+	if cache == nil {
+		return fmt.Errorf("cache %q must not be nil", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.caches[name]; exists {
+		return fmt.Errorf("%w: %q", ErrAlreadyRegistered, name)
+	}
+	r.caches[name] = cache
+	return nil
+}
+
+// MustRegister is like Register, but panics if the cache cannot be registered.
+func (r *Registry) MustRegister(name string, cache Cache) {
+	if err := r.Register(name, cache); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes the cache registered under a given name, without closing it.
+// It returns false if there was no such cache.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.caches[name]; !exists {
+		return false
+	}
+	delete(r.caches, name)
+	return true
+}
+
+// Lookup returns the cache registered under a given name.
+func (r *Registry) Lookup(name string) (Cache, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cache, exists := r.caches[name]
+	return cache, exists
+}
+
+// Names returns the names of all registered caches, in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.caches))
+	for name := range r.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Caches returns a copy of the registered caches, by name.
+func (r *Registry) Caches() map[string]Cache {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	caches := make(map[string]Cache, len(r.caches))
+	for name, cache := range r.caches {
+		caches[name] = cache
+	}
+	return caches
+}
+
+// Range calls f for each registered cache, in name order. If f returns false,
+// the iteration stops.
+//
+// The registry is not locked while calling f, so f may register and
+// unregister caches.
+func (r *Registry) Range(f func(name string, cache Cache) bool) {
+	caches := r.Caches()
+	for _, name := range r.Names() {
+		cache, exists := caches[name]
+		if !exists {
+			continue
+		}
+		if !f(name, cache) {
+			return
+		}
+	}
+}
+
+// Stats returns the sum of the stats of all registered caches.
+func (r *Registry) Stats() StatsSnapshot {
+	var total StatsSnapshot
+	r.Range(func(_ string, cache Cache) bool {
+		total = total.Plus(cache.StatsSnapshot())
+		return true
+	})
+	return total
+}
+
+// InvalidateAll invalidates all entries of all registered caches.
+func (r *Registry) InvalidateAll() {
+	r.Range(func(_ string, cache Cache) bool {
+		cache.InvalidateAll()
+		return true
+	})
+}
+
+// Close closes all registered caches and unregisters them.
+func (r *Registry) Close() {
+	_ = r.Shutdown(context.Background())
+}
+
+// Shutdown shuts down all registered caches in parallel, and unregisters them.
+// It returns the first error returned by a cache, e.g. if the context is done
+// before all in-flight operations completed.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	caches := r.caches
+	r.caches = map[string]Cache{}
+	r.mu.Unlock()
+
+	errs := make(chan error, len(caches))
+	for _, cache := range caches {
+		cache := cache
+		go func() {
+			errs <- cache.Shutdown(ctx)
+		}()
+	}
+	var firstErr error
+	for range caches {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
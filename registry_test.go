@@ -0,0 +1,85 @@
+package loadingcache_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := loadingcache.NewRegistry()
+	defer registry.Close()
+
+	users := loadingcache.New(loadingcache.CacheOptions{})
+	sessions := loadingcache.New(loadingcache.CacheOptions{ShardCount: 2})
+	registry.MustRegister("users", users)
+	registry.MustRegister("sessions", sessions)
+
+	err := registry.Register("users", users)
+	require.True(t, errors.Is(err, loadingcache.ErrAlreadyRegistered))
+	require.Error(t, registry.Register("", users))
+	require.Error(t, registry.Register("nil", nil))
+	require.Panics(t, func() {
+		registry.MustRegister("users", users)
+	})
+
+	require.Equal(t, []string{"sessions", "users"}, registry.Names())
+	require.Equal(t, map[string]loadingcache.Cache{
+		"sessions": sessions,
+		"users":    users,
+	}, registry.Caches())
+
+	cache, exists := registry.Lookup("users")
+	require.True(t, exists)
+	require.Equal(t, users, cache)
+	_, exists = registry.Lookup("missing")
+	require.False(t, exists)
+
+	var names []string
+	registry.Range(func(name string, _ loadingcache.Cache) bool {
+		names = append(names, name)
+		return false
+	})
+	require.Equal(t, []string{"sessions"}, names)
+
+	require.True(t, registry.Unregister("sessions"))
+	require.False(t, registry.Unregister("sessions"))
+	require.Equal(t, []string{"users"}, registry.Names())
+}
+
+func TestRegistryBulkOperations(t *testing.T) {
+	registry := loadingcache.NewRegistry()
+	caches := []loadingcache.Cache{
+		loadingcache.New(loadingcache.CacheOptions{}),
+		loadingcache.New(loadingcache.CacheOptions{ShardCount: 2}),
+	}
+	registry.MustRegister("a", caches[0])
+	registry.MustRegister("b", caches[1])
+
+	for _, cache := range caches {
+		cache.Put(1, "a")
+		cache.Put(2, "b")
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(3)
+		require.Error(t, err)
+	}
+
+	stats := registry.Stats()
+	require.Equal(t, int64(2), stats.HitCount())
+	require.Equal(t, int64(2), stats.MissCount())
+
+	registry.InvalidateAll()
+	for _, cache := range caches {
+		require.Equal(t, 0, cache.Len())
+	}
+
+	registry.Close()
+	require.Empty(t, registry.Names())
+	for _, cache := range caches {
+		_, err := cache.Get(1)
+		require.True(t, errors.Is(err, loadingcache.ErrClosed))
+	}
+}
@@ -1,16 +1,29 @@
 package loadingcache
 
-import "time"
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/Hartimer/loadingcache/internal/stats"
+)
 
 // Stats exposes cache relevant metrics.
 //
 // Be aware that this interface may be exposing a live stats collector, and as such
 // if you manually calculate rates, values may differ if calls to the cache have occurred
-// between calls.
+// between calls. Use Cache.StatsSnapshot to obtain a consistent copy.
 type Stats interface {
 	// EvictionCount is the number of times an entry has been evicted
 	EvictionCount() int64
 
+	// EvictionCountByReason is the number of times an entry has been evicted with
+	// a given reason
+	EvictionCountByReason(reason RemovalReason) int64
+
+	// EvictionWeightByReason is the total weight of the entries evicted with a given reason
+	EvictionWeightByReason(reason RemovalReason) int64
+
 	// HitCount the number of times Cache lookup methods have returned a cached value
 	HitCount() int64
 
@@ -54,4 +67,452 @@ type Stats interface {
 	// AverageLoadPenalty is the average duration spent loading new values. This is defined as
 	// totalLoadTime / (loadSuccessCount + loadExceptionCount).
 	AverageLoadPenalty() time.Duration
+
+	// LoadSuccessLatency describes the distribution of the time spent on successful loads
+	LoadSuccessLatency() LatencyStats
+
+	// LoadErrorLatency describes the distribution of the time spent on failed loads
+	LoadErrorLatency() LatencyStats
+
+	// Windows returns the stats of the last 1, 5 and 15 minutes, in that order.
+	// Unlike the other stats, which are cumulative, they reflect recent changes.
+	Windows() []WindowStats
+
+	// PanicCount is the number of times a user provided function, such as a loading function
+	// or a removal listener, panicked. Loading functions which panicked are also counted as
+	// load errors.
+	PanicCount() int64
+}
+
+// StatsSnapshot is an immutable point in time copy of the cache stats.
+//
+// Unlike a live Stats collector, rates computed from a snapshot are consistent with
+// its counts. Snapshots can be subtracted from each other to compute per interval deltas.
+type StatsSnapshot struct {
+	Hits          int64         `json:"hits"`
+	Misses        int64         `json:"misses"`
+	Evictions     int64         `json:"evictions"`
+	LoadSuccesses int64         `json:"load_successes"`
+	LoadErrors    int64         `json:"load_errors"`
+	TotalLoadTime time.Duration `json:"total_load_time_ns"`
+	Panics        int64         `json:"panics"`
+
+	SuccessLoadLatency LatencyStats `json:"load_success_latency"`
+	ErrorLoadLatency   LatencyStats `json:"load_error_latency"`
+
+	// RecentWindows holds the stats of the last 1, 5 and 15 minutes, in that order.
+	RecentWindows []WindowStats `json:"windows,omitempty"`
+
+	// EvictionsByReason breaks down evictions by their reason. Reasons without
+	// any evictions are omitted.
+	EvictionsByReason map[RemovalReason]EvictionStats `json:"evictions_by_reason,omitempty"`
+}
+
+// statsWindows are the durations reported by Stats.Windows
+var statsWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// WindowStats holds the stats recorded during a recent window of time.
+//
+// Windows are tracked in buckets of 5 seconds driven by the cache clock, so they are
+// approximate at their boundaries.
+type WindowStats struct {
+	Window        time.Duration `json:"window_ns"`
+	Hits          int64         `json:"hits"`
+	Misses        int64         `json:"misses"`
+	LoadSuccesses int64         `json:"load_successes"`
+	LoadErrors    int64         `json:"load_errors"`
+	Evictions     int64         `json:"evictions"`
+}
+
+func newWindowStats(window time.Duration, counts stats.WindowCounts) WindowStats {
+	return WindowStats{
+		Window:        window,
+		Hits:          counts[stats.WindowHits],
+		Misses:        counts[stats.WindowMisses],
+		LoadSuccesses: counts[stats.WindowLoadSuccesses],
+		LoadErrors:    counts[stats.WindowLoadErrors],
+		Evictions:     counts[stats.WindowEvictions],
+	}
+}
+
+// Plus returns the sum of two windows of the same duration.
+func (w WindowStats) Plus(other WindowStats) WindowStats {
+	return WindowStats{
+		Window:        w.Window,
+		Hits:          w.Hits + other.Hits,
+		Misses:        w.Misses + other.Misses,
+		LoadSuccesses: w.LoadSuccesses + other.LoadSuccesses,
+		LoadErrors:    w.LoadErrors + other.LoadErrors,
+		Evictions:     w.Evictions + other.Evictions,
+	}
+}
+
+// HitRate is the ratio of requests in the window which were hits, or 1.0 when there were none
+func (w WindowStats) HitRate() float64 {
+	requestCount := w.Hits + w.Misses
+	if requestCount == 0 {
+		return 1
+	}
+	return float64(w.Hits) / float64(requestCount)
+}
+
+// MissRate is the ratio of requests in the window which were misses, or 0.0 when there were none
+func (w WindowStats) MissRate() float64 {
+	requestCount := w.Hits + w.Misses
+	if requestCount == 0 {
+		return 0
+	}
+	return float64(w.Misses) / float64(requestCount)
+}
+
+// LoadErrorRate is the ratio of loads in the window which failed, or 0.0 when there were none
+func (w WindowStats) LoadErrorRate() float64 {
+	loadCount := w.LoadSuccesses + w.LoadErrors
+	if loadCount == 0 {
+		return 0
+	}
+	return float64(w.LoadErrors) / float64(loadCount)
+}
+
+// LatencyStats summarizes a distribution of durations.
+//
+// Durations are recorded in a log bucketed histogram, so reported percentiles are
+// estimates with a relative error of at most 12.5%. Max is exact for cumulative stats.
+type LatencyStats struct {
+	Count int64         `json:"count"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+	Sum   time.Duration `json:"sum_ns"`
+
+	// histogram is kept so latencies can be merged and subtracted.
+	// It is not serialized.
+	histogram stats.HistogramSnapshot
+}
+
+func newLatencyStats(histogram stats.HistogramSnapshot) LatencyStats {
+	return LatencyStats{
+		Count:     histogram.Count,
+		P50:       histogram.Quantile(0.5),
+		P90:       histogram.Quantile(0.9),
+		P99:       histogram.Quantile(0.99),
+		Max:       histogram.Max,
+		Sum:       histogram.Sum,
+		histogram: histogram,
+	}
+}
+
+// Quantile returns an estimate of the duration below which a given fraction of the
+// recorded durations fall. The fraction must be between 0 and 1.
+//
+// Only the precomputed percentiles survive serialization, so Quantile returns
+// zero for values decoded from JSON.
+func (l LatencyStats) Quantile(q float64) time.Duration {
+	return l.histogram.Quantile(q)
+}
+
+// CountAtOrBelow returns the number of recorded durations which are known to be
+// at or below a given duration. Durations close to it may not be counted, due to
+// the resolution of the histogram. This is useful to export to bucketed histograms.
+//
+// Like Quantile, it returns zero for values decoded from JSON.
+func (l LatencyStats) CountAtOrBelow(d time.Duration) int64 {
+	return l.histogram.CountAtOrBelow(d)
+}
+
+// Plus returns the merge of two latency distributions.
+func (l LatencyStats) Plus(other LatencyStats) LatencyStats {
+	return newLatencyStats(l.histogram.Plus(other.histogram))
+}
+
+// Minus returns the durations recorded in l but not in other, which must be an
+// earlier copy of the same distribution.
+func (l LatencyStats) Minus(other LatencyStats) LatencyStats {
+	return newLatencyStats(l.histogram.Minus(other.histogram))
+}
+
+// EvictionStats describes the evictions of a given RemovalReason.
+type EvictionStats struct {
+	Count  int64 `json:"count"`
+	Weight int64 `json:"weight"`
+}
+
+var (
+	_ Stats = StatsSnapshot{}
+	_ Stats = liveStats{}
+)
+
+// removalReasons lists all known removal reasons.
+var removalReasons = []RemovalReason{
+	RemovalReasonExplicit,
+	RemovalReasonReplaced,
+	RemovalReasonExpired,
+	RemovalReasonSize,
+}
+
+// statsReason maps a removal reason to its internal stats counterpart.
+func (r RemovalReason) statsReason() (stats.EvictionReason, bool) {
+	switch r {
+	case RemovalReasonExplicit:
+		return stats.EvictionReasonExplicit, true
+	case RemovalReasonReplaced:
+		return stats.EvictionReasonReplaced, true
+	case RemovalReasonExpired:
+		return stats.EvictionReasonExpired, true
+	case RemovalReasonSize:
+		return stats.EvictionReasonSize, true
+	default:
+		return 0, false
+	}
+}
+
+// liveStats exposes the internal stats collector through the Stats interface.
+type liveStats struct {
+	*stats.InternalStats
+
+	// clock is used to find the windows which end now
+	clock clock.Clock
+}
+
+func (s liveStats) EvictionCountByReason(reason RemovalReason) int64 {
+	statsReason, ok := reason.statsReason()
+	if !ok {
+		return 0
+	}
+	return s.InternalStats.EvictionCountByReason(statsReason)
+}
+
+func (s liveStats) EvictionWeightByReason(reason RemovalReason) int64 {
+	statsReason, ok := reason.statsReason()
+	if !ok {
+		return 0
+	}
+	return s.InternalStats.EvictionWeightByReason(statsReason)
+}
+
+func (s liveStats) LoadSuccessLatency() LatencyStats {
+	return newLatencyStats(s.InternalStats.LoadSuccessLatency())
+}
+
+func (s liveStats) LoadErrorLatency() LatencyStats {
+	return newLatencyStats(s.InternalStats.LoadErrorLatency())
+}
+
+func (s liveStats) Windows() []WindowStats {
+	now := s.clock.Now()
+	windows := make([]WindowStats, len(statsWindows))
+	for i, window := range statsWindows {
+		windows[i] = newWindowStats(window, s.Window(now, window))
+	}
+	return windows
+}
+
+// newStatsSnapshot copies the current values of a Stats implementation.
+func newStatsSnapshot(s Stats) StatsSnapshot {
+	snapshot := StatsSnapshot{
+		Hits:          s.HitCount(),
+		Misses:        s.MissCount(),
+		Evictions:     s.EvictionCount(),
+		LoadSuccesses: s.LoadSuccessCount(),
+		LoadErrors:    s.LoadErrorCount(),
+		TotalLoadTime: s.LoadTotalTime(),
+		Panics:        s.PanicCount(),
+
+		SuccessLoadLatency: s.LoadSuccessLatency(),
+		ErrorLoadLatency:   s.LoadErrorLatency(),
+		RecentWindows:      s.Windows(),
+	}
+	for _, reason := range removalReasons {
+		snapshot.setEvictions(reason, EvictionStats{
+			Count:  s.EvictionCountByReason(reason),
+			Weight: s.EvictionWeightByReason(reason),
+		})
+	}
+	return snapshot
+}
+
+// plusWindows sums two lists of windows, which must have the same durations.
+// An empty list is treated as all zeroes.
+func plusWindows(windows, other []WindowStats) []WindowStats {
+	if len(windows) == 0 {
+		return append([]WindowStats(nil), other...)
+	}
+	result := make([]WindowStats, len(windows))
+	for i := range windows {
+		result[i] = windows[i]
+		if i < len(other) {
+			result[i] = result[i].Plus(other[i])
+		}
+	}
+	return result
+}
+
+// setEvictions records the evictions of a given reason, omitting empty ones.
+func (s *StatsSnapshot) setEvictions(reason RemovalReason, evictions EvictionStats) {
+	if evictions == (EvictionStats{}) {
+		return
+	}
+	if s.EvictionsByReason == nil {
+		s.EvictionsByReason = make(map[RemovalReason]EvictionStats)
+	}
+	s.EvictionsByReason[reason] = evictions
+}
+
+// Plus returns the sum of two snapshots.
+func (s StatsSnapshot) Plus(other StatsSnapshot) StatsSnapshot {
+	result := StatsSnapshot{
+		Hits:          s.Hits + other.Hits,
+		Misses:        s.Misses + other.Misses,
+		Evictions:     s.Evictions + other.Evictions,
+		LoadSuccesses: s.LoadSuccesses + other.LoadSuccesses,
+		LoadErrors:    s.LoadErrors + other.LoadErrors,
+		TotalLoadTime: s.TotalLoadTime + other.TotalLoadTime,
+		Panics:        s.Panics + other.Panics,
+
+		SuccessLoadLatency: s.SuccessLoadLatency.Plus(other.SuccessLoadLatency),
+		ErrorLoadLatency:   s.ErrorLoadLatency.Plus(other.ErrorLoadLatency),
+		RecentWindows:      plusWindows(s.RecentWindows, other.RecentWindows),
+	}
+	for _, reason := range removalReasons {
+		evictions, otherEvictions := s.EvictionsByReason[reason], other.EvictionsByReason[reason]
+		result.setEvictions(reason, EvictionStats{
+			Count:  evictions.Count + otherEvictions.Count,
+			Weight: evictions.Weight + otherEvictions.Weight,
+		})
+	}
+	return result
+}
+
+// Minus returns the difference between two snapshots. This is useful to compute
+// the stats of an interval, by subtracting the snapshot taken at its start.
+func (s StatsSnapshot) Minus(other StatsSnapshot) StatsSnapshot {
+	result := StatsSnapshot{
+		Hits:          s.Hits - other.Hits,
+		Misses:        s.Misses - other.Misses,
+		Evictions:     s.Evictions - other.Evictions,
+		LoadSuccesses: s.LoadSuccesses - other.LoadSuccesses,
+		LoadErrors:    s.LoadErrors - other.LoadErrors,
+		TotalLoadTime: s.TotalLoadTime - other.TotalLoadTime,
+		Panics:        s.Panics - other.Panics,
+
+		SuccessLoadLatency: s.SuccessLoadLatency.Minus(other.SuccessLoadLatency),
+		ErrorLoadLatency:   s.ErrorLoadLatency.Minus(other.ErrorLoadLatency),
+		// Windows already describe recent activity, so the most recent ones are kept
+		RecentWindows: s.Windows(),
+	}
+	for _, reason := range removalReasons {
+		evictions, otherEvictions := s.EvictionsByReason[reason], other.EvictionsByReason[reason]
+		result.setEvictions(reason, EvictionStats{
+			Count:  evictions.Count - otherEvictions.Count,
+			Weight: evictions.Weight - otherEvictions.Weight,
+		})
+	}
+	return result
+}
+
+// EvictionCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) EvictionCount() int64 {
+	return s.Evictions
+}
+
+// EvictionCountByReason implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) EvictionCountByReason(reason RemovalReason) int64 {
+	return s.EvictionsByReason[reason].Count
+}
+
+// EvictionWeightByReason implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) EvictionWeightByReason(reason RemovalReason) int64 {
+	return s.EvictionsByReason[reason].Weight
+}
+
+// HitCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) HitCount() int64 {
+	return s.Hits
+}
+
+// HitRate implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) HitRate() float64 {
+	requestCount := s.RequestCount()
+	if requestCount == 0 {
+		return 1
+	}
+	return float64(s.Hits) / float64(requestCount)
+}
+
+// MissCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) MissCount() int64 {
+	return s.Misses
+}
+
+// MissRate implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) MissRate() float64 {
+	requestCount := s.RequestCount()
+	if requestCount == 0 {
+		return 0
+	}
+	return float64(s.Misses) / float64(requestCount)
+}
+
+// RequestCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) RequestCount() int64 {
+	return s.Hits + s.Misses
+}
+
+// LoadSuccessCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadSuccessCount() int64 {
+	return s.LoadSuccesses
+}
+
+// LoadErrorCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadErrorCount() int64 {
+	return s.LoadErrors
+}
+
+// LoadErrorRate implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadErrorRate() float64 {
+	loadCount := s.LoadCount()
+	if loadCount == 0 {
+		return 0
+	}
+	return float64(s.LoadErrors) / float64(loadCount)
+}
+
+// LoadCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadCount() int64 {
+	return s.LoadSuccesses + s.LoadErrors
+}
+
+// LoadTotalTime implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadTotalTime() time.Duration {
+	return s.TotalLoadTime
+}
+
+// AverageLoadPenalty implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) AverageLoadPenalty() time.Duration {
+	loadCount := s.LoadCount()
+	if loadCount == 0 {
+		return 0
+	}
+	return s.TotalLoadTime / time.Duration(loadCount)
+}
+
+// PanicCount implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) PanicCount() int64 {
+	return s.Panics
+}
+
+// LoadSuccessLatency implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadSuccessLatency() LatencyStats {
+	return s.SuccessLoadLatency
+}
+
+// LoadErrorLatency implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) LoadErrorLatency() LatencyStats {
+	return s.ErrorLoadLatency
+}
+
+// Windows implements the Stats interface. Refer to its documentation for more details
+func (s StatsSnapshot) Windows() []WindowStats {
+	return append([]WindowStats(nil), s.RecentWindows...)
 }
@@ -2,6 +2,7 @@ package loadingcache_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -82,3 +83,213 @@ func TestLoadSuccessAndError(t *testing.T) {
 			require.Equal(t, float64(0.375), cache.Stats().LoadErrorRate())
 		})
 }
+
+func TestDisableStats(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			DisableStats: true,
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		_, err := cache.Get(1)
+		require.Error(t, err)
+		cache.Put(1, "a")
+		val, err := cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, "a", val)
+
+		require.Equal(t, int64(0), cache.Stats().RequestCount())
+		require.Equal(t, float64(1), cache.Stats().HitRate())
+	})
+}
+
+func TestDisabledStatsDoNotAllocate(t *testing.T) {
+	for name, options := range map[string]loadingcache.CacheOptions{
+		"disabled":        {DisableStats: true},
+		"custom recorder": {StatsRecorder: &testStatsRecorder{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cache := loadingcache.New(options)
+			defer cache.Close()
+			// Only the returned interface value is allocated, not an empty collector
+			allocs := testing.AllocsPerRun(100, func() {
+				_ = cache.Stats()
+			})
+			require.LessOrEqual(t, allocs, float64(1))
+		})
+	}
+}
+
+func TestStatsSnapshot(t *testing.T) {
+	matrixTest(t, matrixTestOptions{}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		cache.Put(1, "a")
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+
+		before := cache.StatsSnapshot()
+		require.Equal(t, int64(1), before.HitCount())
+
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(2)
+		require.Error(t, err)
+
+		// The snapshot is not affected by later calls
+		require.Equal(t, int64(1), before.HitCount())
+		require.Equal(t, int64(0), before.MissCount())
+
+		delta := cache.StatsSnapshot().Minus(before)
+		require.Equal(t, int64(1), delta.HitCount())
+		require.Equal(t, int64(1), delta.MissCount())
+		require.Equal(t, float64(0.5), delta.HitRate())
+	})
+}
+
+func TestStatsSnapshotJSON(t *testing.T) {
+	snapshot := loadingcache.StatsSnapshot{
+		Hits:          3,
+		Misses:        1,
+		LoadSuccesses: 1,
+		TotalLoadTime: time.Second,
+	}
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"hits": 3,
+		"misses": 1,
+		"evictions": 0,
+		"load_successes": 1,
+		"load_errors": 0,
+		"total_load_time_ns": 1000000000,
+		"panics": 0,
+		"load_success_latency": {"count": 0, "p50_ns": 0, "p90_ns": 0, "p99_ns": 0, "max_ns": 0, "sum_ns": 0},
+		"load_error_latency": {"count": 0, "p50_ns": 0, "p90_ns": 0, "p99_ns": 0, "max_ns": 0, "sum_ns": 0}
+	}`, string(data))
+
+	var decoded loadingcache.StatsSnapshot
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, snapshot, decoded)
+}
+
+func TestEvictionsByReason(t *testing.T) {
+	mockClock := clock.NewMock()
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Clock:            mockClock,
+			ExpireAfterWrite: time.Minute,
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		cache.Put(1, "a")
+		cache.Put(1, "b")
+		require.Equal(t, int64(1), cache.Stats().EvictionCountByReason(loadingcache.RemovalReasonReplaced))
+		require.Equal(t, int64(1), cache.Stats().EvictionWeightByReason(loadingcache.RemovalReasonReplaced))
+
+		mockClock.Add(time.Minute + 1)
+		_, err := cache.Get(1)
+		require.Error(t, err)
+		require.Equal(t, int64(1), cache.Stats().EvictionCountByReason(loadingcache.RemovalReasonExpired))
+		require.Equal(t, int64(2), cache.Stats().EvictionCount())
+		require.Equal(t, int64(0), cache.Stats().EvictionCountByReason(loadingcache.RemovalReasonSize))
+
+		snapshot := cache.StatsSnapshot()
+		require.Equal(t, map[loadingcache.RemovalReason]loadingcache.EvictionStats{
+			loadingcache.RemovalReasonReplaced: {Count: 1, Weight: 1},
+			loadingcache.RemovalReasonExpired:  {Count: 1, Weight: 1},
+		}, snapshot.EvictionsByReason)
+
+		// Reasons without new evictions are dropped from deltas
+		cache.Put(2, "a")
+		cache.Put(2, "b")
+		delta := cache.StatsSnapshot().Minus(snapshot)
+		require.Equal(t, map[loadingcache.RemovalReason]loadingcache.EvictionStats{
+			loadingcache.RemovalReasonReplaced: {Count: 1, Weight: 1},
+		}, delta.EvictionsByReason)
+	})
+}
+
+func TestLoadLatency(t *testing.T) {
+	mockClock := clock.NewMock()
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Clock: mockClock,
+			Load: func(key interface{}) (interface{}, error) {
+				// Each load takes as many milliseconds as its key
+				mockClock.Add(time.Duration(key.(int)) * time.Millisecond)
+				if key.(int) > 100 {
+					return nil, errTestLoadFailed
+				}
+				return key, nil
+			},
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		for i := 1; i <= 100; i++ {
+			_, err := cache.Get(i)
+			require.NoError(t, err)
+		}
+		_, err := cache.Get(1000)
+		require.Error(t, err)
+
+		success := cache.Stats().LoadSuccessLatency()
+		require.Equal(t, int64(100), success.Count)
+		require.InEpsilon(t, float64(50*time.Millisecond), float64(success.P50), 0.125)
+		require.InEpsilon(t, float64(90*time.Millisecond), float64(success.P90), 0.125)
+		require.InEpsilon(t, float64(99*time.Millisecond), float64(success.P99), 0.125)
+		require.Equal(t, 100*time.Millisecond, success.Max)
+
+		failure := cache.StatsSnapshot().LoadErrorLatency()
+		require.Equal(t, int64(1), failure.Count)
+		require.Equal(t, time.Second, failure.P99)
+		require.Equal(t, time.Second, failure.Max)
+	})
+}
+
+func TestWindowStats(t *testing.T) {
+	mockClock := clock.NewMock()
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			Clock: mockClock,
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		cache.Put(1, "a")
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(2)
+		require.Error(t, err)
+
+		mockClock.Add(2 * time.Minute)
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+
+		windows := cache.Stats().Windows()
+		require.Len(t, windows, 3)
+		require.Equal(t, time.Minute, windows[0].Window)
+		require.Equal(t, int64(1), windows[0].Hits)
+		require.Equal(t, int64(0), windows[0].Misses)
+		require.Equal(t, float64(1), windows[0].HitRate())
+		require.Equal(t, 5*time.Minute, windows[1].Window)
+		require.Equal(t, int64(2), windows[1].Hits)
+		require.Equal(t, int64(1), windows[1].Misses)
+		require.Equal(t, windows, cache.StatsSnapshot().Windows())
+
+		// Cumulative stats are not affected by windows
+		require.Equal(t, int64(3), cache.Stats().RequestCount())
+	})
+}
+
+func TestResetStats(t *testing.T) {
+	matrixTest(t, matrixTestOptions{}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		cache.Put(1, "a")
+		_, err := cache.Get(1)
+		require.NoError(t, err)
+		_, err = cache.Get(2)
+		require.Error(t, err)
+		require.Equal(t, int64(2), cache.Stats().RequestCount())
+
+		cache.ResetStats()
+		require.Equal(t, int64(0), cache.Stats().RequestCount())
+		require.Equal(t, int64(0), cache.StatsSnapshot().Windows()[2].Hits)
+
+		_, err = cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), cache.Stats().HitCount())
+	})
+}
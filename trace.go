@@ -0,0 +1,125 @@
+package loadingcache
+
+import "context"
+
+// Trace is a set of hooks to run at various stages of a lookup made with GetContext.
+// Any particular hook may be nil.
+//
+// Hooks may be called concurrently by different lookups, and some of them are called
+// while holding the cache's write lock, so they must not call the cache.
+//
+// The trace is removed from the context passed to the loading function, so lookups
+// the loading function makes, e.g. on another cache, are not reported to it.
+type Trace struct {
+	// Hit is called when a cached value is found
+	Hit func(key interface{})
+
+	// SharedLoad is called when the lookup waited for a concurrent lookup of the same key,
+	// and uses the value it loaded instead of loading it again
+	SharedLoad func(key interface{})
+
+	// Miss is called when no value is found, and there is no loading function
+	Miss func(key interface{})
+
+	// LoadStart is called before calling the loading function
+	LoadStart func(key interface{})
+
+	// LoadDone is called after calling the loading function, with the error
+	// returned to the caller, if any
+	LoadDone func(key interface{}, err error)
+}
+
+type traceContextKey struct{}
+
+// WithTrace returns a new context based on the provided parent ctx. Lookups made
+// with the returned context use the provided trace hooks, in addition to any previous
+// hooks registered with ctx.
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	if trace == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey{}, trace.compose(ContextTrace(ctx)))
+}
+
+// ContextTrace returns the Trace associated with the provided context.
+// If none, it returns nil.
+func ContextTrace(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceContextKey{}).(*Trace)
+	return trace
+}
+
+// withoutTrace returns a context which carries no Trace, so lookups made by a loading
+// function with the context it receives do not fire the hooks of the lookup which loads.
+func withoutTrace(ctx context.Context) context.Context {
+	if ContextTrace(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey{}, (*Trace)(nil))
+}
+
+// compose returns a trace which calls the hooks of t, followed by those of old.
+func (t *Trace) compose(old *Trace) *Trace {
+	if old == nil {
+		return t
+	}
+	return &Trace{
+		Hit:        composeKeyHooks(t.Hit, old.Hit),
+		SharedLoad: composeKeyHooks(t.SharedLoad, old.SharedLoad),
+		Miss:       composeKeyHooks(t.Miss, old.Miss),
+		LoadStart:  composeKeyHooks(t.LoadStart, old.LoadStart),
+		LoadDone: func(key interface{}, err error) {
+			if t.LoadDone != nil {
+				t.LoadDone(key, err)
+			}
+			if old.LoadDone != nil {
+				old.LoadDone(key, err)
+			}
+		},
+	}
+}
+
+func composeKeyHooks(hook, old func(key interface{})) func(key interface{}) {
+	switch {
+	case hook == nil:
+		return old
+	case old == nil:
+		return hook
+	}
+	return func(key interface{}) {
+		hook(key)
+		old(key)
+	}
+}
+
+// The following functions call the corresponding hooks, if any.
+// They are safe to call on a nil *Trace.
+
+func (t *Trace) hit(key interface{}) {
+	if t != nil && t.Hit != nil {
+		t.Hit(key)
+	}
+}
+
+func (t *Trace) sharedLoad(key interface{}) {
+	if t != nil && t.SharedLoad != nil {
+		t.SharedLoad(key)
+	}
+}
+
+func (t *Trace) miss(key interface{}) {
+	if t != nil && t.Miss != nil {
+		t.Miss(key)
+	}
+}
+
+func (t *Trace) loadStart(key interface{}) {
+	if t != nil && t.LoadStart != nil {
+		t.LoadStart(key)
+	}
+}
+
+func (t *Trace) loadDone(key interface{}, err error) {
+	if t != nil && t.LoadDone != nil {
+		t.LoadDone(key, err)
+	}
+}
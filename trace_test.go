@@ -0,0 +1,180 @@
+package loadingcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Hartimer/loadingcache"
+	"github.com/stretchr/testify/require"
+)
+
+type testContextKey struct{}
+
+// traceRecorder records the calls to trace hooks
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []string
+	errs   []error
+}
+
+func (r *traceRecorder) record(event string) func(key interface{}) {
+	return func(key interface{}) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.events = append(r.events, event)
+	}
+}
+
+func (r *traceRecorder) trace() *loadingcache.Trace {
+	return &loadingcache.Trace{
+		Hit:        r.record("hit"),
+		SharedLoad: r.record("shared"),
+		Miss:       r.record("miss"),
+		LoadStart:  r.record("start"),
+		LoadDone: func(key interface{}, err error) {
+			r.record("done")(key)
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.errs = append(r.errs, err)
+		},
+	}
+}
+
+func TestGetContext(t *testing.T) {
+	matrixTest(t, matrixTestOptions{
+		cacheOptions: loadingcache.CacheOptions{
+			LoadContext: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+				if key.(int) < 0 {
+					return loadingcache.LoadResult{}, errTestLoadFailed
+				}
+				return loadingcache.LoadResult{Value: ctx.Value(testContextKey{})}, nil
+			},
+		},
+	}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		recorder := &traceRecorder{}
+		ctx := context.WithValue(context.Background(), testContextKey{}, "from context")
+		ctx = loadingcache.WithTrace(ctx, recorder.trace())
+
+		// The context reaches the loading function
+		val, err := cache.GetContext(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, "from context", val)
+
+		val, err = cache.GetContext(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, "from context", val)
+
+		_, err = cache.GetContext(ctx, -1)
+		require.Error(t, err)
+
+		require.Equal(t, []string{"start", "done", "hit", "start", "done"}, recorder.events)
+		require.Nil(t, recorder.errs[0])
+		require.True(t, errors.Is(recorder.errs[1], errTestLoadFailed))
+
+		// Lookups without a context use the background context
+		val, err = cache.Get(2)
+		require.NoError(t, err)
+		require.Nil(t, val)
+	})
+}
+
+func TestTraceMiss(t *testing.T) {
+	matrixTest(t, matrixTestOptions{}, func(t *testing.T, _ context.Context, cache loadingcache.Cache) {
+		recorder := &traceRecorder{}
+		ctx := loadingcache.WithTrace(context.Background(), recorder.trace())
+
+		_, err := cache.GetContext(ctx, 1)
+		require.True(t, errors.Is(err, loadingcache.ErrKeyNotFound))
+		require.Equal(t, []string{"miss"}, recorder.events)
+	})
+}
+
+func TestTraceSharedLoad(t *testing.T) {
+	var loading, started chan struct{}
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		Load: func(key interface{}) (interface{}, error) {
+			close(loading)
+			// The load completes once a concurrent lookup started
+			<-started
+			return "loaded", nil
+		},
+	})
+	defer cache.Close()
+
+	// The concurrent lookup may still find the value once stored, without waiting
+	// for the load, in which case it is a hit. Keys are tried until one is shared.
+	for key := 0; key < 100; key++ {
+		loading, started = make(chan struct{}), make(chan struct{})
+		recorder := &traceRecorder{}
+		ctx := loadingcache.WithTrace(context.Background(), recorder.trace())
+		concurrent := make(chan error)
+		go func() {
+			<-loading
+			close(started)
+			val, err := cache.GetContext(ctx, key)
+			if err == nil && val != "loaded" {
+				err = fmt.Errorf("unexpected value %v", val)
+			}
+			concurrent <- err
+		}()
+
+		val, err := cache.Get(key)
+		require.NoError(t, err)
+		require.Equal(t, "loaded", val)
+		require.NoError(t, <-concurrent)
+		if recorder.events[0] == "shared" {
+			require.Equal(t, []string{"shared"}, recorder.events)
+			return
+		}
+		require.Equal(t, []string{"hit"}, recorder.events)
+	}
+	t.Fatal("no lookup waited for a concurrent load")
+}
+
+func TestWithTraceComposes(t *testing.T) {
+	cache := loadingcache.New(loadingcache.CacheOptions{})
+	defer cache.Close()
+	cache.Put(1, "a")
+
+	outer := &traceRecorder{}
+	inner := &traceRecorder{}
+	ctx := loadingcache.WithTrace(context.Background(), outer.trace())
+	ctx = loadingcache.WithTrace(ctx, &loadingcache.Trace{Hit: inner.record("hit")})
+
+	_, err := cache.GetContext(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"hit"}, outer.events)
+	require.Equal(t, []string{"hit"}, inner.events)
+
+	_, err = cache.GetContext(ctx, 2)
+	require.Error(t, err)
+	require.Equal(t, []string{"hit", "miss"}, outer.events)
+	require.Equal(t, []string{"hit"}, inner.events)
+}
+
+func TestTraceNotPropagatedToLoader(t *testing.T) {
+	inner := loadingcache.New(loadingcache.CacheOptions{})
+	defer inner.Close()
+	inner.Put("x", "x")
+
+	var innerErr error
+	cache := loadingcache.New(loadingcache.CacheOptions{
+		LoadContext: func(ctx context.Context, key interface{}) (loadingcache.LoadResult, error) {
+			// Other values of the context are still propagated
+			_, innerErr = inner.GetContext(ctx, "x")
+			return loadingcache.LoadResult{Value: ctx.Value(testContextKey{})}, innerErr
+		},
+	})
+	defer cache.Close()
+
+	recorder := &traceRecorder{}
+	ctx := context.WithValue(context.Background(), testContextKey{}, "from context")
+	ctx = loadingcache.WithTrace(ctx, recorder.trace())
+	val, err := cache.GetContext(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, "from context", val)
+	require.Equal(t, []string{"start", "done"}, recorder.events)
+}